@@ -0,0 +1,277 @@
+// Command kubectl-leaderlock inspects and releases locks created by the leader
+// package. Installed on the PATH, it can be run as a kubectl plugin:
+//
+//	kubectl leaderlock list
+//	kubectl leaderlock show myapp-lock
+//	kubectl leaderlock release myapp-lock --reason "leader is stuck"
+//	kubectl leaderlock rbac -n myns -service-account myapp | kubectl apply -f -
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/mhrivnak/leaderelection/pkg/leader"
+)
+
+const usage = `Usage: kubectl-leaderlock <command> [flags] [args]
+
+Commands:
+  list                 list locks and their holders
+  show <lock>          show details about a lock
+  release <lock>       force-release a lock, recording the reason as an Event
+  rbac                 print the Role and RoleBinding an election needs
+  readiness-gate       print the pod spec snippet for WithReadinessGate
+
+Run "kubectl-leaderlock <command> -h" for a command's flags.
+`
+
+type command func(args []string) error
+
+var commands = map[string]command{
+	"list":    list,
+	"show":    show,
+	"release": release,
+	"rbac":    rbac,
+
+	"readiness-gate": readinessGate,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// clientFlags are the flags shared by commands that talk to the cluster.
+type clientFlags struct {
+	kubeconfig    string
+	namespace     string
+	allNamespaces bool
+}
+
+func (c *clientFlags) register(fs *flag.FlagSet, allowAll bool) {
+	fs.StringVar(&c.kubeconfig, "kubeconfig", "", "path to the kubeconfig file")
+	fs.StringVar(&c.namespace, "n", "", "namespace of the lock; defaults to the kubeconfig context's namespace")
+	if allowAll {
+		fs.BoolVar(&c.allNamespaces, "A", false, "list locks in all namespaces")
+	}
+}
+
+// client returns a client and the namespace to use, based on the flags and
+// kubeconfig.
+func (c *clientFlags) client() (k8sclient.Interface, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if c.kubeconfig != "" {
+		rules.ExplicitPath = c.kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{}
+	if c.namespace != "" {
+		overrides.Context.Namespace = c.namespace
+	}
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	ns, _, err := config.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+	if c.allNamespaces {
+		ns = metav1.NamespaceAll
+	}
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := k8sclient.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, ns, nil
+}
+
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var cf clientFlags
+	cf.register(fs, true)
+	fs.Parse(args)
+
+	client, ns, err := cf.client()
+	if err != nil {
+		return err
+	}
+	leaders, err := leader.NewAdmin(client).List(ns)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tLOCK\tHOLDER\tKIND\tSTANDBYS\tAGE")
+	for _, l := range leaders {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", l.Namespace, l.Lock, l.Holder, l.HolderKind, len(l.Standbys), l.Age.Round(time.Second))
+	}
+	return w.Flush()
+}
+
+func show(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	var cf clientFlags
+	cf.register(fs, false)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("show requires exactly one lock name")
+	}
+
+	client, ns, err := cf.client()
+	if err != nil {
+		return err
+	}
+	l, err := leader.NewAdmin(client).Get(ns, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Lock:        %s\n", l.Lock)
+	fmt.Printf("Namespace:   %s\n", l.Namespace)
+	fmt.Printf("Holder:      %s %s\n", l.HolderKind, l.Holder)
+	if l.Version != "" {
+		fmt.Printf("Version:     %s\n", l.Version)
+	}
+	if l.Epoch > 0 {
+		fmt.Printf("Epoch:       %d\n", l.Epoch)
+	}
+	if l.Address != "" {
+		fmt.Printf("Address:     %s\n", l.Address)
+	}
+	if l.Port > 0 {
+		fmt.Printf("Peer port:   %d\n", l.Port)
+	}
+	fmt.Printf("Acquired:    %s (%s ago)\n", l.AcquiredAt.Format(time.RFC3339), l.Age.Round(time.Second))
+	if l.HolderKind == "Pod" {
+		pod, err := client.CoreV1().Pods(ns).Get(l.Holder, metav1.GetOptions{})
+		switch {
+		case err != nil:
+			fmt.Printf("Holder pod:  %s\n", err.Error())
+		case pod.DeletionTimestamp != nil:
+			fmt.Printf("Holder pod:  %s, terminating since %s\n", pod.Status.Phase, pod.DeletionTimestamp.Format(time.RFC3339))
+		default:
+			fmt.Printf("Holder pod:  %s on node %s\n", pod.Status.Phase, pod.Spec.NodeName)
+		}
+	}
+	keys := make([]string, 0, len(l.Data))
+	for key := range l.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("Data:        %s=%s\n", key, l.Data[key])
+	}
+	fmt.Printf("Standbys:    %d\n", len(l.Standbys))
+	for _, c := range l.Standbys {
+		fmt.Printf("  %s (seen %s ago)\n", c.Identity, time.Since(c.LastSeen).Round(time.Second))
+	}
+	return nil
+}
+
+func release(args []string) error {
+	fs := flag.NewFlagSet("release", flag.ExitOnError)
+	var cf clientFlags
+	cf.register(fs, false)
+	reason := fs.String("reason", "", "why the lock is being released; required")
+	yes := fs.Bool("y", false, "do not ask for confirmation")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("release requires exactly one lock name")
+	}
+	if *reason == "" {
+		return errors.New("a reason is required")
+	}
+
+	client, ns, err := cf.client()
+	if err != nil {
+		return err
+	}
+	admin := leader.NewAdmin(client)
+	l, err := admin.Get(ns, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		fmt.Printf("Release lock %s/%s held by %s %s? [y/N] ", l.Namespace, l.Lock, l.HolderKind, l.Holder)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			return errors.New("aborted")
+		}
+	}
+
+	if err := admin.ForceRelease(ns, l.Lock, *reason); err != nil {
+		return err
+	}
+	fmt.Printf("Released lock %s/%s.\n", l.Namespace, l.Lock)
+	return nil
+}
+
+func rbac(args []string) error {
+	fs := flag.NewFlagSet("rbac", flag.ExitOnError)
+	var opts leader.RBACOptions
+	fs.StringVar(&opts.Name, "name", leader.DefaultRBACName, "name of the Role and RoleBinding")
+	fs.StringVar(&opts.Namespace, "n", "", "namespace in which the lock is created; required")
+	fs.StringVar(&opts.ServiceAccount, "service-account", "default", "service account the election runs as")
+	fs.BoolVar(&opts.SkipPods, "skip-pods", false, "omit pod permissions, when the owner is provided with WithOwner")
+	fs.BoolVar(&opts.WatchPods, "watch-pods", false, "allow watching pods, so candidates don't have to poll")
+	fs.BoolVar(&opts.Update, "update", false, "allow updating ConfigMaps, as needed by WithLockTTL, WithLease, WithFinalizer and others")
+	fs.BoolVar(&opts.OwnerChain, "owner-chain", false, "allow getting ReplicaSets, as needed by DefaultLockName and WithRolloutStepDown")
+	fs.BoolVar(&opts.LeaderLabel, "leader-label", false, "allow listing and patching pods, as needed by WithLeaderLabel")
+	fs.BoolVar(&opts.StepDown, "step-down", false, "allow listing PodDisruptionBudgets, as needed by WithSafeStepDown")
+	fs.BoolVar(&opts.Observe, "observe", false, "allow listing and watching ConfigMaps, as needed by Observe")
+	fs.BoolVar(&opts.Manager, "manager", false, "allow listing and watching ConfigMaps and pods, as needed by Manager")
+	fs.BoolVar(&opts.ReadinessGate, "readiness-gate", false, "allow patching pod status, as needed by WithReadinessGate")
+	fs.BoolVar(&opts.Events, "events", false, "allow creating Events")
+	fs.BoolVar(&opts.Zones, "zones", false, "allow getting nodes, as needed by WithZonePreference")
+	fs.Parse(args)
+	if opts.Namespace == "" {
+		return errors.New("a namespace is required")
+	}
+
+	out, err := leader.RBACManifest(opts)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+func readinessGate(args []string) error {
+	fs := flag.NewFlagSet("readiness-gate", flag.ExitOnError)
+	condition := fs.String("condition", leader.DefaultReadinessGate, "condition type passed to WithReadinessGate")
+	fs.Parse(args)
+
+	out, err := leader.ReadinessGateSnippet(*condition)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
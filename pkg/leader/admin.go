@@ -0,0 +1,121 @@
+package leader
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+)
+
+// adminComponent is the source recorded on Events created by Admin.
+const adminComponent = "leaderlock-admin"
+
+// Admin inspects and manages locks on behalf of an administrator, typically
+// from outside the cluster.
+type Admin struct {
+	client k8sclient.Interface
+
+	// Clock tells the time against which the age of locks is reported. It
+	// defaults to the system clock.
+	Clock Clock
+}
+
+// NewAdmin returns an Admin that uses the provided client.
+func NewAdmin(client k8sclient.Interface) *Admin {
+	return &Admin{client: client, Clock: realClock{}}
+}
+
+// List returns the locks created by this library in the provided namespace.
+// An empty namespace lists locks in all namespaces.
+func (a *Admin) List(namespace string) ([]LeaderInfo, error) {
+	list, err := a.client.CoreV1().ConfigMaps(namespace).List(metav1.ListOptions{
+		LabelSelector: LockLabel + "=true",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.Clock.Now()
+	leaders := make([]LeaderInfo, 0, len(list.Items))
+	for i := range list.Items {
+		leaders = append(leaders, leaderInfo(&list.Items[i], now))
+	}
+	return leaders, nil
+}
+
+// Get returns the lock with the provided name, along with its holder.
+func (a *Admin) Get(namespace, name string) (LeaderInfo, error) {
+	cm, err := a.client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return LeaderInfo{}, err
+	}
+	return leaderInfo(cm, a.Clock.Now()), nil
+}
+
+// ForceRelease deletes the lock with the provided name so that a new election
+// can take place, even though its holder still exists. Any finalizer placed by
+// this library is removed, so cleanup hooks of the holder do not run. The
+// reason is recorded in an Event on the lock, as an audit trail.
+//
+// The holder is not notified; unless it monitors its lock, as Run does, it
+// will go on acting as the leader.
+func (a *Admin) ForceRelease(namespace, name, reason string) error {
+	cms := a.client.CoreV1().ConfigMaps(namespace)
+	cm, err := cms.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	info := leaderInfo(cm, a.Clock.Now())
+
+	if err := a.recordEvent(cm, "ForceReleased", fmt.Sprintf("lock held by %s %s was force-released: %s", info.HolderKind, info.Holder, reason)); err != nil {
+		return fmt.Errorf("failed to record event, not releasing: %s", err.Error())
+	}
+
+	err = cms.Delete(name, metav1.NewPreconditionDeleteOptions(string(cm.UID)))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if hasFinalizer(cm) {
+		latest, err := cms.Get(name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return err
+		case latest.UID == cm.UID:
+			if err := dropFinalizer(cms.Update, latest); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recordEvent creates a Warning Event about the provided lock.
+func (a *Admin) recordEvent(cm *corev1.ConfigMap, reason, message string) error {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", cm.Name, now.UnixNano()),
+			Namespace: cm.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "ConfigMap",
+			Name:            cm.Name,
+			Namespace:       cm.Namespace,
+			UID:             cm.UID,
+			ResourceVersion: cm.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: adminComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := a.client.CoreV1().Events(cm.Namespace).Create(event)
+	return err
+}
@@ -0,0 +1,89 @@
+package leader
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEvent is the kind of decision recorded in an AuditRecord.
+type AuditEvent string
+
+const (
+	// AuditAttempt records an attempt to become the leader.
+	AuditAttempt AuditEvent = "attempt"
+	// AuditHolderObserved records a new holder seen on the lock.
+	AuditHolderObserved AuditEvent = "holder-observed"
+	// AuditTakeover records the removal of a lock or lease held by a leader
+	// that has gone away.
+	AuditTakeover AuditEvent = "takeover"
+	// AuditAcquired records that this Elector became the leader.
+	AuditAcquired AuditEvent = "acquired"
+	// AuditReleased records that this Elector released the lock.
+	AuditReleased AuditEvent = "released"
+	// AuditLost records that this Elector lost the lock.
+	AuditLost AuditEvent = "lost"
+	// AuditError records an error encountered during the election.
+	AuditError AuditEvent = "error"
+)
+
+// AuditRecord is one line of the log written with WithAuditLog.
+type AuditRecord struct {
+	Time      time.Time  `json:"time"`
+	Lock      string     `json:"lock"`
+	Namespace string     `json:"namespace,omitempty"`
+	Identity  string     `json:"identity,omitempty"`
+	Event     AuditEvent `json:"event"`
+	// Holder is the holder of the lock the event concerns, if any.
+	Holder string `json:"holder,omitempty"`
+	// Attempt is set for AuditAttempt.
+	Attempt int    `json:"attempt,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// auditMu serializes writes to audit logs, so that records from several
+// Electors sharing a writer are not interleaved.
+var auditMu sync.Mutex
+
+// audit appends a record to the audit log, if there is one.
+func (e *Elector) audit(event AuditEvent, holder string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.auditLocked(event, holder, err)
+}
+
+// auditLocked is like audit, for callers that hold e.mu.
+func (e *Elector) auditLocked(event AuditEvent, holder string, err error) {
+	if e.auditLog == nil {
+		return
+	}
+	record := AuditRecord{
+		Time:      e.clock.Now().UTC(),
+		Lock:      e.name,
+		Namespace: e.ns,
+		Identity:  e.identity,
+		Event:     event,
+		Holder:    holder,
+	}
+	if event == AuditAttempt {
+		record.Attempt = e.attempts
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	writeAudit(e.auditLog, record)
+}
+
+func writeAudit(w io.Writer, record AuditRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	// a failing audit log must not disrupt the election
+	_, _ = w.Write(line)
+}
@@ -0,0 +1,76 @@
+package leader
+
+import (
+	"fmt"
+)
+
+// Backend is a way of holding the lock. Both backends keep the lock in a
+// ConfigMap: coordination.k8s.io Leases are not supported, as the client-go
+// this package is built against predates them.
+type Backend string
+
+const (
+	// BackendConfigMap is leader-for-life election: a ConfigMap owned by the
+	// leader's pod, which the garbage collector deletes along with the pod.
+	BackendConfigMap Backend = "configmap"
+	// BackendConfigMapLease is a lease kept in the data of a ConfigMap,
+	// renewed by the leader and taken over with compare-and-swap updates, as
+	// with WithLease. It is not a coordination.k8s.io Lease. It does not need
+	// to watch pods.
+	BackendConfigMapLease Backend = "configmap-lease"
+)
+
+// chooseBackend selects the first of the backends set with WithBackends whose
+// permissions are granted, and configures this Elector to use it. If none
+// qualifies, the error for the first is returned.
+func (e *Elector) chooseBackend() error {
+	if e.leaseFor == 0 {
+		e.leaseFor = e.leaseDuration
+		if e.leaseFor == 0 {
+			e.leaseFor = defaultLeaseDuration
+		}
+	}
+
+	var first error
+	for _, backend := range e.backends {
+		if err := e.useBackend(backend); err != nil {
+			return err
+		}
+		if e.skipPreflight {
+			break
+		}
+		err := e.preflight()
+		if err == nil {
+			e.preflightDone = true
+			break
+		}
+		e.log().Infof("backend %s is not usable: %s", backend, err.Error())
+		if first == nil {
+			first = err
+		}
+		e.mu.Lock()
+		e.backend = ""
+		e.mu.Unlock()
+	}
+	if e.Status().Backend == "" {
+		return first
+	}
+	e.log().Infof("using the %s backend", e.Status().Backend)
+	return nil
+}
+
+// useBackend configures this Elector to use the provided backend.
+func (e *Elector) useBackend(backend Backend) error {
+	switch backend {
+	case BackendConfigMap:
+		e.leaseDuration = 0
+	case BackendConfigMapLease:
+		e.leaseDuration = e.leaseFor
+	default:
+		return fmt.Errorf("unknown lock backend %q", backend)
+	}
+	e.mu.Lock()
+	e.backend = backend
+	e.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,145 @@
+package leader
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// candidatesAnnotation records the candidates waiting for the lock, as a JSON
+// object mapping each identity to when it was last seen, in RFC 3339 format.
+const candidatesAnnotation = "leader.mhrivnak.github.io/candidates"
+
+const (
+	// candidateRefreshInterval is how often a waiting candidate records itself
+	// on the lock.
+	candidateRefreshInterval = 10 * time.Second
+	// candidateTTL is how long a candidate is considered live after it was
+	// last seen.
+	candidateTTL = 3 * candidateRefreshInterval
+	// maxCandidates bounds the number of candidates recorded on a lock. The
+	// most recently seen are kept.
+	maxCandidates = 10
+)
+
+// Candidate is a pod waiting to become the leader, as recorded on the lock.
+type Candidate struct {
+	// Identity is the identity of the candidate, usually the name of its pod.
+	Identity string `json:"identity"`
+	// LastSeen is when the candidate last recorded itself on the lock.
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// lockCandidates returns the candidates recorded on the provided lock, most
+// recently seen first.
+func lockCandidates(cm *corev1.ConfigMap) []Candidate {
+	value, ok := cm.Annotations[candidatesAnnotation]
+	if !ok {
+		return nil
+	}
+	seen := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &seen); err != nil {
+		return nil
+	}
+	candidates := []Candidate{}
+	for identity, timestamp := range seen {
+		lastSeen, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, Candidate{Identity: identity, LastSeen: lastSeen})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].LastSeen.Equal(candidates[j].LastSeen) {
+			return candidates[i].Identity < candidates[j].Identity
+		}
+		return candidates[i].LastSeen.After(candidates[j].LastSeen)
+	})
+	return candidates
+}
+
+// liveCandidates returns the candidates recorded on the provided lock that are
+// not its holder and were seen within candidateTTL of now.
+func liveCandidates(cm *corev1.ConfigMap, now time.Time) []Candidate {
+	holder := lockHolder(cm)
+	live := []Candidate{}
+	for _, c := range lockCandidates(cm) {
+		if c.Identity != holder && now.Sub(c.LastSeen) < candidateTTL {
+			live = append(live, c)
+		}
+	}
+	return live
+}
+
+// withCandidate returns a copy of the provided ConfigMap with identity
+// recorded as a candidate seen now. Stale candidates are dropped, and no more
+// than max are kept, preferring those seen most recently.
+func withCandidate(cm *corev1.ConfigMap, identity string, now time.Time, max int) (*corev1.ConfigMap, error) {
+	candidates := []Candidate{{Identity: identity, LastSeen: now}}
+	for _, c := range liveCandidates(cm, now) {
+		if c.Identity != identity && len(candidates) < max {
+			candidates = append(candidates, c)
+		}
+	}
+	seen := map[string]string{}
+	for _, c := range candidates {
+		seen[c.Identity] = c.LastSeen.UTC().Format(time.RFC3339)
+	}
+	value, err := json.Marshal(seen)
+	if err != nil {
+		return nil, err
+	}
+
+	cm = cm.DeepCopy()
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[candidatesAnnotation] = string(value)
+	return cm, nil
+}
+
+// registerCandidate records this Elector as a candidate on the lock held by
+// another pod, at most once per candidateRefreshInterval. Stale entries are
+// dropped at the same time. On a conflict, as when the leader refreshes the
+// lock at the same time, the entry is recorded on the lock as it is now, unless
+// the lock was replaced. Errors are logged and otherwise ignored; the next
+// attempt tries again.
+//
+// With WithIdentityConflictDetection, an *IdentityConflictError is returned if
+// this Elector's entry was refreshed by someone else since it was last written.
+func (e *Elector) registerCandidate(cm *corev1.ConfigMap) error {
+	if e.detectConflicts && !e.registeredAt.IsZero() {
+		// entries are recorded to the second
+		if e.registeredSince(cm, e.registeredAt.Truncate(time.Second)) {
+			return e.identityConflict()
+		}
+	}
+	if e.skipRegistry || cm.DeletionTimestamp != nil || e.since(e.registeredAt) < candidateRefreshInterval {
+		return nil
+	}
+	now := e.clock.Now()
+
+	_, err := e.updateLock(cm, func(cm *corev1.ConfigMap) error {
+		updated, err := withCandidate(cm, e.identity, now, maxCandidates)
+		if err != nil {
+			return err
+		}
+		cm.Annotations[candidatesAnnotation] = updated.Annotations[candidatesAnnotation]
+		return nil
+	})
+	switch {
+	case err == nil:
+		e.registeredAt = now
+		if e.migrating != nil {
+			e.registerMigrating()
+		}
+	case err == ErrLeadershipLost, apierrors.IsConflict(err), apierrors.IsNotFound(err):
+		e.log().Debugf("lock changed while registering as a candidate: %s", err.Error())
+	default:
+		e.log().Warnf("failed to register as a candidate: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package leader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// interfere changes the named lock with fn, as another pod would, just before
+// the first update of it is handled, so that the update conflicts.
+func interfere(t *testing.T, cluster *fakeCluster, name string, fn func(cm *corev1.ConfigMap)) {
+	var once sync.Once
+	cluster.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.UpdateAction).GetObject().(*corev1.ConfigMap).Name == name {
+			once.Do(func() { cluster.modify(t, name, fn) })
+		}
+		return false, nil, nil
+	})
+}
+
+func TestRegisterCandidateConflict(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), testLock("lock", "pod-b", nil))
+	// the leader refreshes the lock as pod-a records itself
+	interfere(t, cluster, "lock", func(cm *corev1.ConfigMap) {
+		cm.Annotations[heartbeatAnnotation] = clock.Now().UTC().Format(time.RFC3339)
+	})
+	e := newTestElector("lock", cluster, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return e.Become(ctx) })
+	// recorded by the first attempt, without waiting for another
+	eventually(t, clock, 0, func() bool { return len(lockCandidates(cluster.lock(t, "lock"))) != 0 })
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cm := cluster.lock(t, "lock")
+	if cm.Annotations[heartbeatAnnotation] == "" {
+		t.Error("the leader's change was overwritten")
+	}
+	if candidates := lockCandidates(cm); len(candidates) != 1 || candidates[0].Identity != "pod-a" {
+		t.Errorf("candidates are %v, expected pod-a", candidates)
+	}
+}
@@ -0,0 +1,96 @@
+package leader
+
+import (
+	"context"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations recorded on child locks created with Child.
+const (
+	// parentAnnotation records the name of the parent lock.
+	parentAnnotation = "leader.mhrivnak.github.io/parent"
+	// parentEpochAnnotation records the epoch of the parent's leadership
+	// under which the child lock was acquired.
+	parentEpochAnnotation = "leader.mhrivnak.github.io/parent-epoch"
+)
+
+// Child returns an Elector for a child lock scoped to this Elector's current
+// leadership, for work such as per-resource workers spawned by a global leader.
+// The child lock records the name of its parent and is owned by the parent's
+// lock, so the garbage collector deletes it along with the parent. Child locks
+// held by this Elector are also released as soon as its leadership ends, and a
+// child lock left over from an earlier term of the parent is taken over by the
+// next candidate for it.
+//
+// The child uses this Elector's namespace, client and identity, in addition to
+// the provided options. ErrNotLeader is returned if this Elector is not the
+// leader.
+func (e *Elector) Child(name string, opts ...Option) (*Elector, error) {
+	status := e.Status()
+	if status.State != StateLeader {
+		return nil, ErrNotLeader
+	}
+
+	all := []Option{
+		WithNamespace(e.ns),
+		WithClient(e.client),
+		WithClock(e.clock),
+		WithSleeper(e.sleeper),
+		WithIdentityProvider(StaticIdentity(e.identity, metav1.OwnerReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       e.name,
+			UID:        e.lockUID,
+		})),
+	}
+	child := NewElector(name, append(all, opts...)...)
+	child.parent = e
+	child.parentEpoch = status.Epoch
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.children = append(e.children, child)
+	return child, nil
+}
+
+// releaseChildren releases the child locks held by this Elector, once its own
+// leadership has ended. Errors are logged and otherwise ignored; the garbage
+// collector or the next parent cleans up after any that remain.
+func (e *Elector) releaseChildren(ctx context.Context) {
+	e.mu.Lock()
+	children := e.children
+	e.children = nil
+	e.mu.Unlock()
+
+	for _, child := range children {
+		if child.Status().State != StateLeader {
+			continue
+		}
+		if err := child.release(ctx); err != nil {
+			e.log().Warnf("failed to release child lock %s: %s", child.name, err.Error())
+		}
+	}
+}
+
+// annotateParent records the parent of a child lock in its annotations.
+func (e *Elector) annotateParent(annotations map[string]string) {
+	if e.parent == nil {
+		return
+	}
+	annotations[parentAnnotation] = e.parent.name
+	annotations[parentEpochAnnotation] = strconv.FormatInt(e.parentEpoch, 10)
+}
+
+// staleChild returns true if the provided lock is a child lock that was
+// acquired under an earlier term of this Elector's parent. Its holder's
+// leadership of the parent has ended, so the lock may be taken over.
+func (e *Elector) staleChild(cm *corev1.ConfigMap) bool {
+	if e.parent == nil || cm.Annotations[parentAnnotation] != e.parent.name {
+		return false
+	}
+	epoch, err := strconv.ParseInt(cm.Annotations[parentEpochAnnotation], 10, 64)
+	return err == nil && epoch < e.parentEpoch
+}
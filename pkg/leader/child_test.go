@@ -0,0 +1,125 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testChild returns a child lock called name of the parent lock, acquired by
+// holder under the provided epoch of the parent.
+func testChild(name, holder, parent, parentEpoch string) *corev1.ConfigMap {
+	cm := testLock(name, holder, map[string]string{
+		parentAnnotation:      parent,
+		parentEpochAnnotation: parentEpoch,
+	})
+	cm.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: parent, UID: "previous-parent"}}
+	return cm
+}
+
+func TestChild(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testLock("parent", "pod-a", map[string]string{epochAnnotation: "3"}))
+	parent := newTestElector("parent", cluster, clock)
+	if _, err := parent.Child("child", WithoutPreflight()); err != ErrNotLeader {
+		t.Errorf("Child returned %v before the parent leads, expected ErrNotLeader", err)
+	}
+	if err := parent.campaign(context.Background()); err != nil {
+		t.Fatalf("campaign failed: %s", err.Error())
+	}
+
+	child, err := parent.Child("child", WithoutPreflight())
+	if err != nil {
+		t.Fatalf("Child failed: %s", err.Error())
+	}
+	if err := await(t, clock, 0, async(func() error { return child.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	cm := cluster.lock(t, "child")
+	if got := lockHolder(cm); got != "pod-a" {
+		t.Errorf("child lock is held by %q, expected pod-a", got)
+	}
+	if len(cm.OwnerReferences) != 1 || cm.OwnerReferences[0].Kind != "ConfigMap" || cm.OwnerReferences[0].UID != "parent-pod-a" {
+		t.Errorf("child lock is owned by %v, expected the parent lock", cm.OwnerReferences)
+	}
+	if cm.Annotations[parentAnnotation] != "parent" || cm.Annotations[parentEpochAnnotation] != "3" {
+		t.Errorf("child lock records parent %q at epoch %q, expected parent at epoch 3",
+			cm.Annotations[parentAnnotation], cm.Annotations[parentEpochAnnotation])
+	}
+
+	// the child lock is released as soon as the parent's leadership ends
+	if err := cluster.tracker.Delete(configMapsResource, testNamespace, "parent"); err != nil {
+		t.Fatal(err)
+	}
+	if err := await(t, clock, lockCheckInterval, async(func() error { return parent.maintain(context.Background()) })); err != ErrLeadershipLost {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if cm := cluster.lock(t, "child"); cm != nil {
+		t.Errorf("child lock still exists, held by %q", lockHolder(cm))
+	}
+	if state := child.Status().State; state != StateCandidate {
+		t.Errorf("child state is %s, expected candidate", state)
+	}
+}
+
+func TestChildTakeover(t *testing.T) {
+	tests := []struct {
+		name         string
+		child        *corev1.ConfigMap
+		wantTakeover bool
+	}{
+		{
+			name:         "child of an earlier term",
+			child:        testChild("child", "pod-b", "parent", "2"),
+			wantTakeover: true,
+		},
+		{
+			name:  "child of the current term",
+			child: testChild("child", "pod-b", "parent", "3"),
+		},
+		{
+			name:  "child of another parent",
+			child: testChild("child", "pod-b", "other", "1"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), tc.child,
+				testLock("parent", "pod-a", map[string]string{epochAnnotation: "3"}))
+			parent := newTestElector("parent", cluster, clock)
+			if err := parent.campaign(context.Background()); err != nil {
+				t.Fatalf("campaign failed: %s", err.Error())
+			}
+			child, err := parent.Child("child", WithoutPreflight())
+			if err != nil {
+				t.Fatalf("Child failed: %s", err.Error())
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			result := async(func() error { return child.Become(ctx) })
+			if !tc.wantTakeover {
+				never(t, clock, time.Second, result)
+				if got := lockHolder(cluster.lock(t, "child")); got != "pod-b" {
+					t.Errorf("child lock is held by %q, expected pod-b", got)
+				}
+				cancel()
+				if err := await(t, clock, 0, result); err != context.Canceled {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err := await(t, clock, time.Second, result); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+			if got := lockHolder(cluster.lock(t, "child")); got != "pod-a" {
+				t.Errorf("child lock is held by %q, expected pod-a", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package leader
+
+import (
+	"time"
+)
+
+// Clock tells the current time. It can be replaced with WithClock so that
+// timing-dependent behavior can be tested without waiting.
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper waits for time to pass. It can be replaced with WithSleeper so that
+// retries and periodic checks can be tested without waiting.
+type Sleeper interface {
+	// After returns a channel that receives once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock implements Clock and Sleeper with the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// since returns the time elapsed since t according to the Elector's clock.
+func (e *Elector) since(t time.Time) time.Duration {
+	return e.clock.Now().Sub(t)
+}
@@ -0,0 +1,178 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// instanceAnnotation records a random token that identifies the process
+// holding the lock. Unlike the holder's identity, it differs between two
+// processes that were mistakenly given the same identity, and between a
+// process and its restarted self.
+const instanceAnnotation = "leader.mhrivnak.github.io/instance"
+
+// heartbeatAnnotation is when the process holding a ConfigMap lock last
+// recorded that it is alive, by its own clock, in RFC 3339 format. Only
+// changes to it matter, so clock skew does not.
+const heartbeatAnnotation = "leader.mhrivnak.github.io/heartbeat"
+
+const (
+	// heartbeatInterval is how often the leader records a heartbeat.
+	heartbeatInterval = time.Second * 10
+	// heartbeatTimeout is how long a process that finds a lock held under its
+	// identity by another instance watches for a heartbeat before concluding
+	// that the other instance is gone, such as its own self before a restart.
+	heartbeatTimeout = 2*heartbeatInterval + lockCheckInterval
+)
+
+// IdentityConflictError indicates that another live process is campaigning or
+// leading under the same identity as this Elector, as happens when two
+// processes run in one pod or hostnames collide. This is a configuration error:
+// both processes would otherwise believe they are the leader.
+type IdentityConflictError struct {
+	// Identity is the identity in use by more than one process.
+	Identity string
+}
+
+func (e *IdentityConflictError) Error() string {
+	return fmt.Sprintf("another process is using the identity %q", e.Identity)
+}
+
+// identityConflict records that another process uses this Elector's identity,
+// and returns the error to report.
+func (e *Elector) identityConflict() error {
+	e.mu.Lock()
+	err := &IdentityConflictError{Identity: e.identity}
+	e.mu.Unlock()
+	e.log().Error(err.Error())
+	identityConflictsCounter.WithLabelValues(e.name).Inc()
+	return err
+}
+
+// otherInstance returns true if the provided lock, held under this Elector's
+// identity, was last acquired by some other process. Locks created before the
+// instance was recorded are assumed to be this process's.
+func (e *Elector) otherInstance(cm *corev1.ConfigMap) bool {
+	instance := cm.Annotations[instanceAnnotation]
+	return instance != "" && instance != e.instance
+}
+
+// staleOwner returns true if the provided lock, held under this Elector's
+// identity, is owned by an object of the same kind as owner but with a
+// different UID. StatefulSet pods, and Jobs, reuse names when they are
+// recreated, so such a lock was held by a predecessor that no longer exists.
+func (e *Elector) staleOwner(cm *corev1.ConfigMap, owner metav1.OwnerReference) bool {
+	if owner.UID == "" {
+		return false
+	}
+	for _, ref := range cm.GetOwnerReferences() {
+		if ref.Kind == owner.Kind && ref.Name == owner.Name && ref.UID != owner.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitInstanceGone watches the provided lock, held under this Elector's
+// identity by another instance, for a heartbeat. If one arrives within
+// heartbeatTimeout, that instance is alive and an *IdentityConflictError is
+// returned. Otherwise the lock, as last read, is returned to be resumed. If the
+// lock is deleted or replaced meanwhile, nil is returned.
+func (e *Elector) awaitInstanceGone(ctx context.Context, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	heartbeat := cm.Annotations[heartbeatAnnotation]
+	start := e.clock.Now()
+	for e.since(start) < heartbeatTimeout {
+		select {
+		case <-e.sleeper.After(time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		latest, err := e.client.CoreV1().ConfigMaps(e.ns).Get(e.name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			return nil, nil
+		case err != nil:
+			e.log().Warnf("failed to get lock: %s", err.Error())
+			continue
+		case latest.UID != cm.UID:
+			return nil, nil
+		case latest.Annotations[heartbeatAnnotation] != heartbeat ||
+			latest.Annotations[instanceAnnotation] != cm.Annotations[instanceAnnotation]:
+			err := e.identityConflict()
+			e.setError(err)
+			return nil, err
+		}
+		cm = latest
+	}
+	return cm, nil
+}
+
+// heartbeat records on the lock that this process is alive, at most once per
+// heartbeatInterval, retrying if a candidate updates the lock at the same
+// time. It is called by maintain alone. Errors are logged and otherwise
+// ignored; the next check tries again.
+func (e *Elector) heartbeat(cm *corev1.ConfigMap) {
+	if e.since(e.heartbeatAt) < heartbeatInterval {
+		return
+	}
+	now := e.clock.Now()
+	_, err := e.updateLock(cm, func(cm *corev1.ConfigMap) error {
+		cm.Annotations[heartbeatAnnotation] = now.UTC().Format(time.RFC3339)
+		return nil
+	})
+	if err != nil {
+		e.log().Debugf("failed to record heartbeat: %s", err.Error())
+		return
+	}
+	e.heartbeatAt = now
+}
+
+// claimInstance records this process as the instance holding a lock that was
+// found to be held under this Elector's identity, once any other instance is
+// known to be gone, so that a process that was wrongly taken for gone notices
+// the conflict.
+func (e *Elector) claimInstance(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if cm.Annotations[instanceAnnotation] == e.instance {
+		return cm, nil
+	}
+	cm = cm.DeepCopy()
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[instanceAnnotation] = e.instance
+	updated, err := e.client.CoreV1().ConfigMaps(e.ns).Update(cm)
+	if err != nil {
+		e.log().Error("failed to record this process on the lock")
+		return nil, e.permissionError(err, "update", "configmaps")
+	}
+	return updated, nil
+}
+
+// candidateSeen returns when the provided lock records a candidate with this
+// Elector's identity as last seen, or the zero time if there is none.
+func (e *Elector) candidateSeen(cm *corev1.ConfigMap) time.Time {
+	e.mu.Lock()
+	identity := e.identity
+	e.mu.Unlock()
+	for _, c := range lockCandidates(cm) {
+		if c.Identity == identity {
+			return c.LastSeen
+		}
+	}
+	return time.Time{}
+}
+
+// registeredSince returns true if the provided lock records a candidate with
+// this Elector's identity that was seen at other than the provided time, as
+// read from the lock earlier. A leader does not register as a candidate, so
+// that entry was written by another process. Only times read from the lock are
+// compared, so clock skew does not matter.
+func (e *Elector) registeredSince(cm *corev1.ConfigMap, seen time.Time) bool {
+	last := e.candidateSeen(cm)
+	return !last.IsZero() && !last.Equal(seen)
+}
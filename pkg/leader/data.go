@@ -0,0 +1,89 @@
+package leader
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// ErrReservedKey indicates an attempt to set lock data under a key that the
+// library uses itself.
+var ErrReservedKey = errors.New("key is reserved for the lease")
+
+// reservedKeys are the keys of the lock's data used by the library.
+var reservedKeys = map[string]bool{
+	leaseHolderKey:   true,
+	leaseRenewKey:    true,
+	leaseDurationKey: true,
+	leaseAcquireKey:  true,
+}
+
+// UpdateLeaderData publishes small amounts of state, such as a checkpoint or
+// endpoints, in the lock's data. Keys with an empty value are removed; other
+// keys are left alone. Each write verifies that this Elector still holds the
+// lock, and ErrNotLeader is returned if it does not. The next leader receives
+// the data as a Handoff; see Elector.Handoff for how current it is.
+func (e *Elector) UpdateLeaderData(ctx context.Context, data map[string]string) error {
+	if e.Status().State != StateLeader {
+		return ErrNotLeader
+	}
+	for key := range data {
+		if reservedKeys[key] {
+			return ErrReservedKey
+		}
+	}
+
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cm, err := cms.Get(e.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if cm.UID != e.lockUID || cm.DeletionTimestamp != nil || lockHolder(cm) != e.identity {
+			return ErrNotLeader
+		}
+		cm = cm.DeepCopy()
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		for key, value := range data {
+			if value == "" {
+				delete(cm.Data, key)
+			} else {
+				cm.Data[key] = value
+			}
+		}
+		_, err = cms.Update(cm)
+		return err
+	})
+}
+
+// LeaderData returns the data published on the lock with UpdateLeaderData,
+// whoever holds it.
+func (e *Elector) LeaderData(ctx context.Context) (map[string]string, error) {
+	if err := e.setup(); err != nil {
+		return nil, err
+	}
+	cm, err := e.client.CoreV1().ConfigMaps(e.ns).Get(e.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return leaderData(cm), nil
+}
+
+// leaderData returns the data of the provided lock, without reserved keys.
+func leaderData(cm *corev1.ConfigMap) map[string]string {
+	data := map[string]string{}
+	for key, value := range cm.Data {
+		if !reservedKeys[key] {
+			data[key] = value
+		}
+	}
+	return data
+}
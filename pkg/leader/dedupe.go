@@ -0,0 +1,49 @@
+package leader
+
+import (
+	"sync"
+	"time"
+)
+
+// Transitions that notifications are deduplicated on.
+const (
+	transitionAcquired = "acquired"
+	transitionLost     = "lost"
+)
+
+// notification is the last transition notified for a lock.
+type notification struct {
+	transition string
+	at         time.Time
+}
+
+// notified records the last transition notified for each lock in this
+// process, so that a burst of identical transitions within the dedupe window
+// produces only one notification.
+var (
+	notifiedMu sync.Mutex
+	notified   = map[string]notification{}
+)
+
+// shouldNotify returns true if hooks, metrics and the Pushgateway should be
+// notified of the provided transition, to or from holder, of this Elector's
+// lock. A transition is identified by the lock object, by UID, as well as its
+// holder, so resuming the same term of leadership, as a repeated Become does,
+// is the same transition, while a new term is not. It returns false if the
+// same transition was the last one notified for the lock, by any Elector in
+// this process, within the window set with WithDedupeWindow. A different
+// transition in between, such as a loss between two acquisitions, is always
+// notified. e.mu must be held.
+func (e *Elector) shouldNotify(transition, holder string) bool {
+	if e.dedupeWindow <= 0 {
+		return true
+	}
+	key := e.ns + "/" + e.name
+	n := notification{transition: transition + "/" + holder + "/" + string(e.lockUID), at: e.clock.Now()}
+
+	notifiedMu.Lock()
+	defer notifiedMu.Unlock()
+	last, ok := notified[key]
+	notified[key] = n
+	return !ok || last.transition != n.transition || n.at.Sub(last.at) >= e.dedupeWindow
+}
@@ -0,0 +1,94 @@
+package leader
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrDryRun is returned by Become and Run when WithDryRun is used, after the
+// dry run has been reported.
+var ErrDryRun = errors.New("dry run; leadership was not acquired")
+
+// DryRunReport describes what an election would do, without doing it.
+type DryRunReport struct {
+	// Namespace is the namespace of the lock.
+	Namespace string `json:"namespace"`
+	// Identity is the identity the lock would be held under.
+	Identity string `json:"identity"`
+	// Lock is the lock that would be created.
+	Lock *corev1.ConfigMap `json:"lock"`
+	// MissingPermissions lists permissions the election needs but lacks.
+	MissingPermissions []Permission `json:"missingPermissions,omitempty"`
+	// Holder is the current holder of the lock, or empty if it doesn't
+	// exist.
+	Holder string `json:"holder,omitempty"`
+	// WouldResume is true if the lock is already held under Identity, as
+	// after a restart.
+	WouldResume bool `json:"wouldResume"`
+}
+
+// DryRun resolves the namespace and identity, checks permissions, and reports
+// what lock would be created and who currently holds it. Nothing is created,
+// changed or deleted.
+func (e *Elector) DryRun(ctx context.Context) (DryRunReport, error) {
+	if err := e.setup(); err != nil {
+		return DryRunReport{}, err
+	}
+	if e.identity == "" {
+		if err := e.resolveOwner(); err != nil {
+			return DryRunReport{}, err
+		}
+	}
+
+	report := DryRunReport{
+		Namespace: e.ns,
+		Identity:  e.identity,
+		Lock:      e.newLock(e.owner),
+	}
+
+	err := e.preflight()
+	switch perr := err.(type) {
+	case nil:
+	case *PermissionError:
+		report.MissingPermissions = perr.Missing
+	default:
+		return report, err
+	}
+
+	existing, err := e.client.CoreV1().ConfigMaps(e.ns).Get(e.name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		report.Holder = lockHolder(existing)
+		report.WouldResume = report.Holder == e.identity
+	case apierrors.IsNotFound(err):
+	default:
+		return report, err
+	}
+	return report, nil
+}
+
+// reportDryRun runs DryRun and logs its report.
+func (e *Elector) reportDryRun(ctx context.Context) error {
+	report, err := e.DryRun(ctx)
+	if err != nil {
+		return err
+	}
+	log := e.log().WithField("dryRun", true)
+	log.Infof("would create lock %s/%s owned by %s %s", report.Namespace, report.Lock.Name, e.owner.Kind, e.owner.Name)
+	switch {
+	case report.WouldResume:
+		log.Info("lock is already held by this identity; would continue as the leader")
+	case report.Holder != "":
+		log.Infof("lock is currently held by %s; would wait", report.Holder)
+	default:
+		log.Info("lock is free; would become the leader")
+	}
+	for _, p := range report.MissingPermissions {
+		log.Warnf("missing permission: %s", p)
+	}
+	return ErrDryRun
+}
@@ -0,0 +1,628 @@
+package leader
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// initialBackoff and maxBackoff bound the default delay between attempts
+	// to become the leader, which doubles after each failed attempt.
+	initialBackoff = time.Second * 1
+	maxBackoff     = time.Second * 16
+)
+
+// Elector runs a leader-for-life election for a single lock.
+type Elector struct {
+	name   string
+	ns     string
+	client k8sclient.Interface
+
+	hostname     string
+	podNameFile  string
+	finalizer    bool
+	cleanupHooks []func(ctx context.Context) error
+	// releasing is set, with WithFinalizer, once Release has marked the lock
+	// for deletion, so that maintain does not take that for a loss. It is
+	// guarded by mu.
+	releasing bool
+	// yielding is set while Release deletes the lock, so that maintain does
+	// not take its disappearance for a loss. It is guarded by mu.
+	yielding bool
+	// term is closed when the current term as leader ends, which stops the
+	// maintain calls watching over it. It is guarded by mu.
+	term chan struct{}
+
+	metricsAddr     string
+	pushURL         string
+	pushJob         string
+	dryRun          bool
+	releaseOnReturn bool
+	releaseOnCancel bool
+	skipPreflight   bool
+	preflightDone   bool
+
+	// pollPods is set when the service account may not watch pods, so
+	// candidates poll every pollInterval instead.
+	pollPods     bool
+	pollInterval time.Duration
+	// skipRegistry is set when the service account may not update
+	// ConfigMaps, so candidates don't record themselves on the lock.
+	skipRegistry bool
+
+	// backends are set with WithBackends, and backend is the one in use.
+	// leaseFor is the lease duration BackendConfigMapLease uses.
+	backends []Backend
+	backend  Backend
+	leaseFor time.Duration
+
+	// lockTTL is set with WithLockTTL. refreshedAt is when this Elector last
+	// stamped an expiry on the lock it holds. expiryVersion identifies the
+	// expiry last seen on another's lock, and expiryObservedAt is when that
+	// was first seen.
+	lockTTL          time.Duration
+	refreshedAt      time.Time
+	expiryVersion    string
+	expiryObservedAt time.Time
+
+	// leaseDuration is set with WithLease. The remaining fields track when
+	// the lease was last renewed by this Elector, and when it was last seen
+	// to change.
+	leaseDuration   time.Duration
+	renewedAt       time.Time
+	leaseVersion    string
+	leaseObservedAt time.Time
+
+	outagePolicy    OutagePolicy
+	outageTolerance time.Duration
+	lossPolicy      LossPolicy
+
+	safeStepDown bool
+	stepDownWait time.Duration
+
+	// rolloutStepDown is set with WithRolloutStepDown. deploymentUID and
+	// revision describe the current pod, once known; revision is noRevision
+	// if it is not managed by a Deployment.
+	rolloutStepDown bool
+	deploymentUID   types.UID
+	revision        int
+
+	takeOverTerminating bool
+	terminatingSlack    time.Duration
+
+	// a successor observed on the lock, which this Elector defers to until
+	// the deadline
+	successor         string
+	successorDeadline time.Time
+
+	// when this Elector last recorded itself as a candidate on the lock
+	registeredAt time.Time
+	// detectConflicts is set with WithIdentityConflictDetection.
+	// heartbeatAt is when maintain last recorded a heartbeat on the lock.
+	detectConflicts bool
+	heartbeatAt     time.Time
+
+	// identity is the name this Elector holds the lock under, usually the
+	// name of its pod
+	identity string
+	// instance identifies this process among any that share its identity
+	instance string
+	// identityProvider determines identity and owner; if nil, the current
+	// pod is looked up by hostname
+	identityProvider IdentityProvider
+	jobOwner         bool
+	// podName, podUID and podIP identify the current pod, if the lock is
+	// owned by it or its Job
+	podName string
+	podUID  types.UID
+	podIP   string
+	// peerPort is the port on which this Elector's process accepts
+	// connections from its peers, if set
+	peerPort int
+
+	leaderLabelKey   string
+	leaderLabelValue string
+	podAnnotations   bool
+	readinessGate    string
+
+	// ordinal is the StatefulSet ordinal of the current pod, or -1
+	ordinal     int
+	ordinalStep time.Duration
+
+	startupJitter time.Duration
+	jittered      bool
+
+	// zone is the zone of the current pod's node, if zonePreference is set,
+	// and lastLeaderZone that of the most recently observed leader
+	zonePreference *ZonePreference
+	zone           string
+	lastLeaderZone string
+
+	// owner and lockUID identify the lock held by this Elector
+	owner   metav1.OwnerReference
+	lockUID types.UID
+	// checkedLockUID is the last lock checked for compatible features
+	checkedLockUID types.UID
+
+	// legacyName is set with WithLegacyLockName, and legacy is the Elector
+	// for that lock. legacyRetired is set once the legacy lock is no longer
+	// needed; it is guarded by mu. migrating is the Elector that set up this
+	// one for its legacy lock.
+	legacyName    string
+	legacy        *Elector
+	legacyRetired bool
+	migrating     *Elector
+
+	// shared is the Manager this Elector belongs to, if any
+	shared *Manager
+
+	// parent is the Elector that created this one with Child, under the
+	// parent's leadership epoch parentEpoch. children are those this
+	// Elector created, guarded by mu.
+	parent      *Elector
+	parentEpoch int64
+	children    []*Elector
+
+	// pendingHandoff is the data last seen on the lock while waiting, which
+	// becomes handoff once this Elector leads. Both are guarded by mu.
+	pendingHandoff *Handoff
+	handoff        *Handoff
+
+	clock   Clock
+	sleeper Sleeper
+	backoff workqueue.RateLimiter
+
+	gate        *Gate
+	acquired    chan struct{}
+	acquireOnce sync.Once
+	hooks       Hooks
+	auditLog    io.Writer
+	// dedupeWindow is set with WithDedupeWindow
+	dedupeWindow time.Duration
+	mutateLock   func(obj metav1.Object)
+
+	standbyAfter time.Duration
+	onStandby    func(holder string)
+	hookRunner   hookRunner
+
+	// leaderWork counts the leader functions running under Run and
+	// BecomeAndRun, and signalCleanup the lock deletions by HandleSignals
+	// that wait for them, so that the lock outlives leader work.
+	leaderWork    sync.WaitGroup
+	signalCleanup sync.WaitGroup
+
+	mu         sync.Mutex
+	state      State
+	holder     string
+	acquiredAt time.Time
+	attempts   int
+	lastErr    error
+	// leaderFor, transitions, takeovers and errorCount are reported by
+	// Report. leaderFor excludes the current term.
+	leaderFor   time.Duration
+	transitions int
+	takeovers   int
+	errorCount  int
+	// epoch is that of the current term, and lastEpoch the highest seen
+	epoch     int64
+	lastEpoch int64
+	// waitingSince is when this Elector started waiting to become the
+	// leader, or zero while leading.
+	waitingSince time.Time
+	// standbyNotified is set once onStandby has been called for the
+	// current campaign.
+	standbyNotified bool
+}
+
+// NewElector returns an Elector for the lock with the provided name.
+func NewElector(name string, opts ...Option) *Elector {
+	e := &Elector{
+		name:     name,
+		state:    StateCandidate,
+		gate:     newGate(),
+		acquired: make(chan struct{}),
+
+		ordinal:      -1,
+		clock:        realClock{},
+		sleeper:      realClock{},
+		backoff:      workqueue.NewItemExponentialFailureRateLimiter(initialBackoff, maxBackoff),
+		podNameFile:  DefaultPodNameFile,
+		pollInterval: defaultPollInterval,
+		instance:     utilrand.String(10),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Become ensures that the current pod is the leader, blocking until it is or
+// until ctx is done. See the package-level Become for details.
+//
+// ErrElectionInProgress is returned if another Elector in this process is
+// campaigning for the same lock under the same identity at the same time. As
+// with Run, the election's Report is logged and passed to Hooks.OnStop before
+// Become returns.
+func (e *Elector) Become(ctx context.Context) error {
+	defer e.stopped()
+	return e.becomeUntil(ctx, ctx)
+}
+
+// becomeUntil campaigns until acquireCtx is done, and then holds the lock until
+// holdCtx is done, which is when WithReleaseOnCancel releases it. This lets
+// callers bound each attempt without the lock being released once acquired.
+func (e *Elector) becomeUntil(acquireCtx, holdCtx context.Context) error {
+	if err := e.campaign(acquireCtx); err != nil {
+		return err
+	}
+	// the term acquired by this call, which callers like Group may give up
+	// and acquire again before these goroutines are scheduled
+	term := e.currentTerm()
+	if e.releaseOnCancel {
+		go func() {
+			e.maintainTerm(holdCtx, term)
+			if !termEnded(term) {
+				e.releaseCancelled(holdCtx)
+			}
+		}()
+	} else if e.lockTTL > 0 || e.leaseDuration > 0 || e.rolloutStepDown || e.detectConflicts {
+		// nothing else is going to refresh or check on the lock
+		go e.maintainTerm(context.Background(), term)
+	}
+	return nil
+}
+
+// campaign blocks until this Elector is the leader or ctx is done.
+func (e *Elector) campaign(ctx context.Context) error {
+	if e.dryRun {
+		return e.reportDryRun(ctx)
+	}
+	e.log().Info("trying to become the leader")
+
+	err := e.become(ctx)
+	if err != nil && err != ctx.Err() {
+		e.setError(err)
+	}
+	return err
+}
+
+func (e *Elector) become(ctx context.Context) error {
+	err := e.setup()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	if e.waitingSince.IsZero() {
+		e.waitingSince = e.clock.Now()
+	}
+	e.mu.Unlock()
+
+	if err := e.startupDelay(ctx); err != nil {
+		return err
+	}
+
+	if len(e.backends) > 0 && e.Status().Backend == "" {
+		if err := e.chooseBackend(); err != nil {
+			return err
+		}
+	}
+
+	if !e.skipPreflight && !e.preflightDone {
+		if err := e.preflight(); err != nil {
+			return err
+		}
+		e.preflightDone = true
+	}
+
+	if e.identity == "" {
+		if err := e.resolveOwner(); err != nil {
+			return err
+		}
+	}
+	owner := e.owner
+
+	if err := e.claimCampaign(); err != nil {
+		return err
+	}
+	defer e.releaseCampaign()
+
+	if e.legacyName != "" {
+		if err := e.acquireLegacy(ctx); err != nil {
+			return err
+		}
+	}
+
+	if e.leaseDuration > 0 {
+		return e.becomeLease(ctx)
+	}
+
+	cm := e.newLock(owner)
+
+	// check for existing lock from this pod, in case we got restarted
+	existing, err := e.client.CoreV1().ConfigMaps(e.ns).Get(e.name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		holder := lockHolder(existing)
+		if holder == e.identity && e.staleOwner(existing, owner) {
+			e.log().Info("Found existing lock with my name, owned by a previous pod of the same name. Removing it.")
+			e.setHolder(holder)
+			e.forceDeleteLock(existing)
+			break
+		}
+		if holder == e.identity && e.detectConflicts && e.otherInstance(existing) && existing.Annotations[heartbeatAnnotation] != "" {
+			e.log().Info("Found existing lock with my name from another process. Waiting to see whether it is still alive.")
+			existing, err = e.awaitInstanceGone(ctx, existing)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				e.log().Info("The lock went away while waiting.")
+				break
+			}
+		}
+		if holder == e.identity {
+			e.log().Info("Found existing lock with my name. I was likely restarted.")
+			if e.detectConflicts {
+				existing, err = e.claimInstance(existing)
+				if err != nil {
+					return err
+				}
+			}
+			if e.lockTTL > 0 {
+				// its previous holder may have stopped refreshing it
+				if err := e.refreshExpiry(existing); err != nil {
+					e.log().Error("failed to refresh lock expiry")
+					return err
+				}
+			}
+			e.log().Info("Continuing as the leader.")
+			e.lockUID = existing.UID
+			e.setEpoch(existing)
+			e.observeHandoff(existing)
+			e.setLeader(e.identity, existing.CreationTimestamp.Time)
+			return nil
+		}
+		e.log().Infof("Found existing lock from %s", holder)
+		e.setHolder(holder)
+		e.observeEpoch(existing)
+		e.observeHandoff(existing)
+	case apierrors.IsNotFound(err):
+		e.log().Info("No pre-existing lock was found.")
+	default:
+		e.log().Error("unknown error trying to get ConfigMap")
+		return e.permissionError(err, "get", "configmaps")
+	}
+
+	// try to create a lock
+	var w *ownerWatch
+	defer func() { w.stop() }()
+	integrityFailures := 0
+	for {
+		if successor, ok := e.deferringTo(); ok {
+			e.log().Infof("Deferring to designated successor %s.", successor)
+			select {
+			case <-e.sleeper.After(time.Second * 1):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err := e.delayAttempt(ctx); err != nil {
+			return err
+		}
+
+		// the lock carries the epoch of its term from the start
+		counter, epoch, err := e.readEpoch()
+		if err != nil {
+			if _, ok := err.(*PermissionError); ok {
+				return err
+			}
+			e.log().Warnf("failed to read the epoch counter: %s", err.Error())
+			e.setError(err)
+			select {
+			case <-e.sleeper.After(e.backoff.When(e.name)):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		cm.ObjectMeta.Annotations[epochAnnotation] = strconv.FormatInt(epoch, 10)
+
+		e.addAttempt()
+		if e.lockTTL > 0 {
+			// the TTL runs from creation, not from when the campaign began
+			e.stampExpiry(cm.ObjectMeta.Annotations)
+		}
+		created, err := e.client.CoreV1().ConfigMaps(e.ns).Create(cm)
+		switch {
+		case err == nil:
+			if err := e.verifyCreated(created); err != nil {
+				if _, ok := err.(*IntegrityError); !ok {
+					return err
+				}
+				integrityFailures++
+				if integrityFailures >= maxIntegrityFailures {
+					return err
+				}
+				e.setError(err)
+				select {
+				case <-e.sleeper.After(e.backoff.When(e.name)):
+					continue
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if err := e.claimEpoch(counter, epoch); err != nil {
+				e.log().Warnf("failed to record epoch %d; giving up the lock: %s", epoch, err.Error())
+				e.abandonLock(created)
+				if _, ok := err.(*PermissionError); ok {
+					return err
+				}
+				e.setError(err)
+				select {
+				case <-e.sleeper.After(e.backoff.When(e.name)):
+					continue
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			e.log().Info("Became the leader.")
+			e.lockUID = created.UID
+			e.setEpoch(created)
+			e.setLeader(e.identity, e.clock.Now())
+			return nil
+		case apierrors.IsAlreadyExists(err):
+			e.log().Info("Not the leader. Waiting.")
+			existing, retry, err := e.inspectLock()
+			e.checkStandby()
+			if err != nil {
+				return err
+			}
+			if retry {
+				continue
+			}
+			if existing != nil {
+				w = e.watchOwner(w, existing)
+			}
+			var deleted chan struct{}
+			if w != nil {
+				deleted = w.deleted
+			}
+			delay := e.backoff.When(e.name)
+			if e.pollPods && delay > e.pollInterval {
+				delay = e.pollInterval
+			}
+			select {
+			case <-e.sleeper.After(delay):
+			case <-deleted:
+				e.log().Info("The leader's pod was deleted.")
+				w.deleted = nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case apierrors.IsForbidden(err):
+			e.log().Error("not allowed to create configmap")
+			return e.permissionError(err, "create", "configmaps")
+		default:
+			e.log().Error("unknown error creating configmap")
+			return err
+		}
+	}
+}
+
+// resolveOwner determines the identity and owner of this Elector's lock with
+// its IdentityProvider. By default the lock is owned by the current pod, found
+// by hostname. With WithJobOwner, a lock owned by a pod is owned by its Job
+// instead.
+func (e *Elector) resolveOwner() error {
+	provider := e.identityProvider
+	if provider == nil {
+		provider = hostnameIdentity{log: e.log(), hostname: e.hostname, podNameFile: e.podNameFile}
+	}
+	id, err := provider.Identity(e.client, e.ns)
+	if err != nil {
+		return e.permissionError(err, "get", "pods")
+	}
+	e.setIdentity(id.Name)
+	e.owner = id.Owner
+	pod := id.Pod
+	if pod == nil {
+		return nil
+	}
+	e.podName = pod.Name
+	e.podUID = pod.UID
+	e.podIP = pod.Status.PodIP
+	e.ordinal = statefulSetOrdinal(pod)
+	if e.zonePreference != nil {
+		e.zone = e.nodeZone(pod)
+		e.log().Infof("found zone: %s", e.zone)
+	}
+
+	if e.jobOwner {
+		if ref := metav1.GetControllerOf(pod); ref != nil && ref.Kind == "Job" {
+			e.owner = metav1.OwnerReference{
+				APIVersion: ref.APIVersion,
+				Kind:       ref.Kind,
+				Name:       ref.Name,
+				UID:        ref.UID,
+			}
+		} else {
+			e.log().Warn("pod is not owned by a Job; the lock will be owned by the pod")
+		}
+	}
+	return nil
+}
+
+// setIdentity sets the identity this Elector holds the lock under.
+func (e *Elector) setIdentity(identity string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.identity = identity
+}
+
+// log returns a logger that attaches the lock, namespace, identity and
+// attempt count to each message, so that messages from several elections in
+// one process can be told apart.
+func (e *Elector) log() *logrus.Entry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fields := logrus.Fields{
+		"lock":    e.name,
+		"attempt": e.attempts,
+	}
+	if e.ns != "" {
+		fields["namespace"] = e.ns
+	}
+	if e.identity != "" {
+		fields["identity"] = e.identity
+	}
+	return logrus.WithFields(fields)
+}
+
+// setup resolves the namespace and client, if they were not provided as
+// options.
+func (e *Elector) setup() error {
+	if e.externalIdentity() && e.leaseDuration <= 0 && len(e.backends) == 0 {
+		return ErrIdentityWithoutLease
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	isLeaderGauge.WithLabelValues(e.name).Set(0)
+	if e.metricsAddr != "" {
+		serveMetrics(e.metricsAddr)
+	}
+
+	if e.ns == "" {
+		ns, err := myNS(e.log())
+		if err != nil {
+			return err
+		}
+		e.mu.Lock()
+		e.ns = ns
+		e.mu.Unlock()
+	}
+
+	if e.client == nil {
+		client, err := getClientset()
+		if err != nil {
+			return err
+		}
+		e.client = client
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package leader
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestBecome(t *testing.T) {
+	running, completed := testPod("pod-b"), testPod("pod-b")
+	completed.Status.Phase = corev1.PodSucceeded
+	// a later pod of the same name, as a StatefulSet would create
+	completedLater := completed.DeepCopy()
+	completedLater.UID = "later-uid"
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		// free, if set, is called once Become is waiting, and makes the lock
+		// available
+		free      func(t *testing.T, cluster *fakeCluster)
+		wantUID   types.UID
+		wantEpoch int64
+	}{
+		{
+			name:      "no lock",
+			wantUID:   "uid-1",
+			wantEpoch: 1,
+		},
+		{
+			name:      "own lock is resumed",
+			objs:      []runtime.Object{testLock("lock", "pod-a", map[string]string{epochAnnotation: "4"})},
+			wantUID:   "lock-pod-a",
+			wantEpoch: 4,
+		},
+		{
+			name: "own lock from a previous pod of the same name is replaced",
+			objs: []runtime.Object{func() runtime.Object {
+				cm := testLock("lock", "pod-a", map[string]string{epochAnnotation: "4"})
+				cm.OwnerReferences[0].UID = "previous-uid"
+				return cm
+			}()},
+			wantUID:   "uid-1",
+			wantEpoch: 1,
+		},
+		{
+			name:      "lock of a completed pod is taken over",
+			objs:      []runtime.Object{completed, testLock("lock", "pod-b", map[string]string{epochAnnotation: "2"})},
+			wantUID:   "uid-1",
+			wantEpoch: 3,
+		},
+		{
+			name: "lock owned by a Job is taken over once its holder completes",
+			objs: []runtime.Object{completed, func() runtime.Object {
+				cm := testLock("lock", "pod-b", map[string]string{epochAnnotation: "2", holderUIDAnnotation: "pod-b-uid"})
+				cm.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "job", UID: "job-uid"}}
+				return cm
+			}()},
+			wantUID:   "uid-1",
+			wantEpoch: 3,
+		},
+		{
+			name: "lock of a pod is not taken over when a later pod of its name completes",
+			objs: []runtime.Object{completedLater, testLock("lock", "pod-b", map[string]string{epochAnnotation: "2"})},
+			free: func(t *testing.T, cluster *fakeCluster) {
+				cluster.deletePod(t, "pod-b")
+			},
+			wantUID:   "uid-1",
+			wantEpoch: 3,
+		},
+		{
+			name: "lock of a running pod is acquired once the pod is deleted",
+			objs: []runtime.Object{running, testLock("lock", "pod-b", map[string]string{epochAnnotation: "2"})},
+			free: func(t *testing.T, cluster *fakeCluster) {
+				cluster.deletePod(t, "pod-b")
+			},
+			wantUID:   "uid-1",
+			wantEpoch: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, append(tc.objs, testPod("pod-a"))...)
+			e := newTestElector("lock", cluster, clock)
+
+			result := async(func() error { return e.Become(context.Background()) })
+			if tc.free != nil {
+				never(t, clock, maxBackoff, result)
+				if holder := e.Status().Holder; holder != "pod-b" {
+					t.Errorf("waiting on holder %q, expected pod-b", holder)
+				}
+				tc.free(t, cluster)
+			}
+			if err := await(t, clock, maxBackoff, result); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+
+			status := e.Status()
+			if status.State != StateLeader || status.Holder != "pod-a" {
+				t.Errorf("state is %s with holder %q, expected leader pod-a", status.State, status.Holder)
+			}
+			if status.Epoch != tc.wantEpoch {
+				t.Errorf("epoch is %d, expected %d", status.Epoch, tc.wantEpoch)
+			}
+			cm := cluster.lock(t, "lock")
+			if cm == nil {
+				t.Fatal("lock does not exist")
+			}
+			if cm.UID != tc.wantUID {
+				t.Errorf("lock UID is %s, expected %s", cm.UID, tc.wantUID)
+			}
+			if got := lockHolder(cm); got != "pod-a" {
+				t.Errorf("lock is held by %q, expected pod-a", got)
+			}
+			if !ownedBy(cm, podOwnerRef(testPod("pod-a"))) {
+				t.Errorf("lock is owned by %s, expected pod-a-uid", ownerUIDs(cm))
+			}
+			if got := cm.Annotations[instanceAnnotation]; cm.UID == "uid-1" && got != e.instance {
+				t.Errorf("lock records instance %q, expected %q", got, e.instance)
+			}
+			if got := cm.Annotations[holderUIDAnnotation]; cm.UID == "uid-1" && got != "pod-a-uid" {
+				t.Errorf("lock records holder UID %q, expected pod-a-uid", got)
+			}
+			if got := lockEpoch(cm); got != tc.wantEpoch {
+				t.Errorf("lock records epoch %d, expected %d", got, tc.wantEpoch)
+			}
+		})
+	}
+}
+
+func TestBecomeEpochsIncrease(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+
+	for want := int64(1); want <= 3; want++ {
+		e := newTestElector("lock", cluster, clock)
+		if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+			t.Fatalf("Become failed: %s", err.Error())
+		}
+		if got := e.Status().Epoch; got != want {
+			t.Errorf("epoch is %d, expected %d", got, want)
+		}
+		counter := cluster.lock(t, "lock"+epochCounterSuffix)
+		if counter == nil || counter.Data[epochKey] != strconv.FormatInt(want, 10) {
+			t.Errorf("epoch counter is %v, expected %d", counter, want)
+		}
+		if err := e.Release(context.Background()); err != nil {
+			t.Fatalf("Release failed: %s", err.Error())
+		}
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		change  func(t *testing.T, cluster *fakeCluster)
+		wantErr func(err error) bool
+	}{
+		{
+			name: "lock deleted",
+			change: func(t *testing.T, cluster *fakeCluster) {
+				if err := cluster.tracker.Delete(configMapsResource, testNamespace, "lock"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: func(err error) bool { return err == ErrLeadershipLost },
+		},
+		{
+			name: "lock replaced",
+			change: func(t *testing.T, cluster *fakeCluster) {
+				if err := cluster.tracker.Delete(configMapsResource, testNamespace, "lock"); err != nil {
+					t.Fatal(err)
+				}
+				if err := cluster.tracker.Add(testLock("lock", "pod-b", nil)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: func(err error) bool { return err == ErrLeadershipLost },
+		},
+		{
+			name: "candidate registered under the same identity",
+			opts: []Option{WithIdentityConflictDetection()},
+			change: func(t *testing.T, cluster *fakeCluster) {
+				cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+					cm.Annotations[candidatesAnnotation] = `{"pod-a":"2019-12-31T23:00:00Z"}`
+				})
+			},
+			wantErr: func(err error) bool {
+				_, ok := err.(*IdentityConflictError)
+				return ok
+			},
+		},
+		{
+			name: "lock claimed by another process",
+			opts: []Option{WithIdentityConflictDetection()},
+			change: func(t *testing.T, cluster *fakeCluster) {
+				cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+					cm.Annotations[instanceAnnotation] = "other"
+				})
+			},
+			wantErr: func(err error) bool {
+				conflict, ok := err.(*IdentityConflictError)
+				return ok && conflict.Identity == "pod-a"
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"))
+			e := newTestElector("lock", cluster, clock, tc.opts...)
+			if err := e.campaign(context.Background()); err != nil {
+				t.Fatalf("campaign failed: %s", err.Error())
+			}
+
+			result := async(func() error { return e.maintain(context.Background()) })
+			never(t, clock, lockCheckInterval, result)
+			tc.change(t, cluster)
+			if err := await(t, clock, lockCheckInterval, result); !tc.wantErr(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if state := e.Status().State; state != StateCandidate {
+				t.Errorf("state is %s, expected candidate", state)
+			}
+		})
+	}
+}
+
+func TestMaintainHeartbeat(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	e := newTestElector("lock", cluster, clock, WithIdentityConflictDetection())
+	if err := e.campaign(context.Background()); err != nil {
+		t.Fatalf("campaign failed: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := async(func() error { return e.maintain(ctx) })
+	var last string
+	for i := 0; i < 3; i++ {
+		eventually(t, clock, lockCheckInterval, func() bool {
+			beat := cluster.lock(t, "lock").Annotations[heartbeatAnnotation]
+			if beat == "" || beat == last {
+				return false
+			}
+			last = beat
+			return true
+		})
+	}
+	if want := clock.Now().Add(-heartbeatInterval).UTC().Format(time.RFC3339); last < want {
+		t.Errorf("last heartbeat is %s, expected %s or later", last, want)
+	}
+
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if state := e.Status().State; state != StateLeader {
+		t.Errorf("state is %s, expected leader", state)
+	}
+}
+
+func TestBecomeIdentityConflict(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		opts        []Option
+		// beat, if set, makes the other instance record heartbeats while
+		// Become waits
+		beat     bool
+		wantWait bool
+		wantErr  bool
+	}{
+		{
+			name:        "lock of another instance is resumed without detection",
+			annotations: map[string]string{instanceAnnotation: "other", heartbeatAnnotation: "2020-01-01T00:00:00Z"},
+			beat:        true,
+		},
+		{
+			name:        "lock without a heartbeat is resumed at once",
+			annotations: map[string]string{instanceAnnotation: "other"},
+			opts:        []Option{WithIdentityConflictDetection()},
+		},
+		{
+			name:        "lock of a gone instance is resumed once it misses a heartbeat",
+			annotations: map[string]string{instanceAnnotation: "other", heartbeatAnnotation: "2020-01-01T00:00:00Z"},
+			opts:        []Option{WithIdentityConflictDetection()},
+			wantWait:    true,
+		},
+		{
+			name:        "lock of a live instance is a conflict",
+			annotations: map[string]string{instanceAnnotation: "other", heartbeatAnnotation: "2020-01-01T00:00:00Z"},
+			opts:        []Option{WithIdentityConflictDetection()},
+			beat:        true,
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"), testLock("lock", "pod-a", tc.annotations))
+			e := newTestElector("lock", cluster, clock, tc.opts...)
+
+			start := clock.Now()
+			result := async(func() error { return e.Become(context.Background()) })
+			if tc.beat {
+				time.Sleep(time.Millisecond * 5)
+				clock.Step(heartbeatInterval)
+				cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+					cm.Annotations[heartbeatAnnotation] = clock.Now().UTC().Format(time.RFC3339)
+				})
+			}
+			err := await(t, clock, time.Second, result)
+			if _, ok := err.(*IdentityConflictError); ok != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+			if waited := clock.Now().Sub(start); tc.wantWait != (waited >= heartbeatTimeout) {
+				t.Errorf("resumed after %s; wait for a missed heartbeat expected: %t", waited, tc.wantWait)
+			}
+			if got := cluster.lock(t, "lock").UID; got != "lock-pod-a" {
+				t.Errorf("lock UID is %s, expected lock-pod-a", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package leader
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Environment variables read by NewFromEnv.
+const (
+	// EnvLockName is the name of the lock. It is required.
+	EnvLockName = "LEADER_ELECTION_LOCK_NAME"
+	// EnvLockType is "configmap", the default, for leader-for-life election,
+	// or "lease" for WithLease. A comma-separated list, such as
+	// "configmap-lease,configmap", is passed to WithBackends.
+	EnvLockType = "LEADER_ELECTION_LOCK_TYPE"
+	// EnvNamespace is passed to WithNamespace.
+	EnvNamespace = "LEADER_ELECTION_NAMESPACE"
+	// EnvLeaseDuration is passed to WithLease. It defaults to 15s.
+	EnvLeaseDuration = "LEADER_ELECTION_LEASE_DURATION"
+	// EnvLockTTL is passed to WithLockTTL.
+	EnvLockTTL = "LEADER_ELECTION_LOCK_TTL"
+	// EnvRetryPeriod and EnvMaxRetryPeriod bound the delay between attempts,
+	// as with WithBackoff. They default to 1s and 16s.
+	EnvRetryPeriod    = "LEADER_ELECTION_RETRY_PERIOD"
+	EnvMaxRetryPeriod = "LEADER_ELECTION_MAX_RETRY_PERIOD"
+	// EnvTerminatingSlack is passed to WithTerminatingLeaderSlack.
+	EnvTerminatingSlack = "LEADER_ELECTION_TERMINATING_SLACK"
+	// EnvStartupJitter is passed to WithStartupJitter.
+	EnvStartupJitter = "LEADER_ELECTION_STARTUP_JITTER"
+	// EnvMetricsAddr is passed to WithMetricsServer.
+	EnvMetricsAddr = "LEADER_ELECTION_METRICS_ADDR"
+	// EnvFinalizer, if true, enables WithFinalizer.
+	EnvFinalizer = "LEADER_ELECTION_FINALIZER"
+)
+
+// defaultLeaseDuration is the lease duration used by NewFromEnv and
+// BackendConfigMapLease when none is set.
+const defaultLeaseDuration = time.Second * 15
+
+// EnvError lists every problem found in the environment by NewFromEnv.
+type EnvError struct {
+	Problems []string
+}
+
+func (e *EnvError) Error() string {
+	return "invalid leader election environment: " + strings.Join(e.Problems, "; ")
+}
+
+// NewFromEnv returns an Elector configured from LEADER_ELECTION_* environment
+// variables, for deployments configured through the environment alone, such as
+// by a Helm chart. Durations use Go's duration syntax, such as "30s". All
+// problems are reported together in an *EnvError. The provided options are
+// applied after those from the environment.
+func NewFromEnv(opts ...Option) (*Elector, error) {
+	var problems []string
+	duration := func(key string) time.Duration {
+		value := os.Getenv(key)
+		if value == "" {
+			return 0
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			problems = append(problems, fmt.Sprintf("%s must be a non-negative duration, got %q", key, value))
+			return 0
+		}
+		return d
+	}
+
+	name := os.Getenv(EnvLockName)
+	if name == "" {
+		problems = append(problems, EnvLockName+" is required")
+	} else if err := ValidateName(name); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %s", EnvLockName, err.Error()))
+	}
+
+	envOpts := []Option{}
+	switch lockType := os.Getenv(EnvLockType); {
+	case strings.Contains(lockType, ","):
+		backends := []Backend{}
+		for _, b := range strings.Split(lockType, ",") {
+			backend := Backend(strings.TrimSpace(b))
+			if backend != BackendConfigMap && backend != BackendConfigMapLease {
+				problems = append(problems, fmt.Sprintf("%s lists unknown backend %q", EnvLockType, backend))
+			}
+			backends = append(backends, backend)
+		}
+		if lease := duration(EnvLeaseDuration); lease > 0 {
+			envOpts = append(envOpts, WithLease(lease))
+		}
+		envOpts = append(envOpts, WithBackends(backends...))
+	case lockType == "" || lockType == "configmap":
+		if os.Getenv(EnvLeaseDuration) != "" {
+			problems = append(problems, EnvLeaseDuration+" requires "+EnvLockType+"=lease")
+		}
+	case lockType == "lease":
+		lease := duration(EnvLeaseDuration)
+		if lease == 0 {
+			lease = defaultLeaseDuration
+		}
+		envOpts = append(envOpts, WithLease(lease))
+	default:
+		problems = append(problems, fmt.Sprintf("%s must be \"configmap\" or \"lease\", got %q", EnvLockType, lockType))
+	}
+
+	if ns := os.Getenv(EnvNamespace); ns != "" {
+		envOpts = append(envOpts, WithNamespace(ns))
+	}
+	if ttl := duration(EnvLockTTL); ttl > 0 {
+		envOpts = append(envOpts, WithLockTTL(ttl))
+	}
+
+	retry, maxRetry := duration(EnvRetryPeriod), duration(EnvMaxRetryPeriod)
+	if retry > 0 || maxRetry > 0 {
+		if retry == 0 {
+			retry = initialBackoff
+		}
+		if maxRetry == 0 {
+			maxRetry = maxBackoff
+		}
+		if maxRetry < retry {
+			problems = append(problems, EnvMaxRetryPeriod+" must not be less than "+EnvRetryPeriod)
+		}
+		envOpts = append(envOpts, WithBackoff(workqueue.NewItemExponentialFailureRateLimiter(retry, maxRetry)))
+	}
+
+	if value := os.Getenv(EnvTerminatingSlack); value != "" {
+		envOpts = append(envOpts, WithTerminatingLeaderSlack(duration(EnvTerminatingSlack)))
+	}
+	if jitter := duration(EnvStartupJitter); jitter > 0 {
+		envOpts = append(envOpts, WithStartupJitter(jitter))
+	}
+	if addr := os.Getenv(EnvMetricsAddr); addr != "" {
+		envOpts = append(envOpts, WithMetricsServer(addr))
+	}
+	if value := os.Getenv(EnvFinalizer); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s must be true or false, got %q", EnvFinalizer, value))
+		} else if enabled {
+			envOpts = append(envOpts, WithFinalizer())
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &EnvError{Problems: problems}
+	}
+	return NewElector(name, append(envOpts, opts...)...), nil
+}
@@ -0,0 +1,160 @@
+package leader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// epochAnnotation records the leadership epoch: a counter that increases each
+// time a different leader, or a new term of the same one, acquires the lock.
+// Resuming leadership after a restart keeps the epoch.
+const epochAnnotation = "leader.mhrivnak.github.io/epoch"
+
+const (
+	// epochCounterSuffix is appended to the lock name to name the ConfigMap
+	// that counts the epochs of a lock that is deleted each term. It is owned
+	// by nothing, so the count outlives every leader, and it is left behind
+	// when the election is no longer used. Lock names may not end with it.
+	epochCounterSuffix = ".epoch"
+	// epochCounterLabel marks epoch counters, so that leftover ones can be
+	// found and deleted.
+	epochCounterLabel = "leader.mhrivnak.github.io/epoch-counter"
+	// epochKey is the key of the count in the counter's data.
+	epochKey = "epoch"
+)
+
+// lockEpoch returns the epoch recorded on the provided lock, or zero.
+func lockEpoch(cm *corev1.ConfigMap) int64 {
+	epoch, err := strconv.ParseInt(cm.Annotations[epochAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return epoch
+}
+
+// observeEpoch records the epoch of a lock seen by this Elector. The highest
+// seen is the floor for the next epoch, so that the count never goes back, even
+// for a lock that predates its counter.
+func (e *Elector) observeEpoch(cm *corev1.ConfigMap) {
+	epoch := lockEpoch(cm)
+	if epoch > 0 {
+		epochGauge.WithLabelValues(e.name).Set(float64(epoch))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if epoch > e.lastEpoch {
+		e.lastEpoch = epoch
+	}
+}
+
+// stampEpoch records the epoch of a new term of leadership in the provided
+// lock annotations. It suits a lease, which outlives each leader and is taken
+// over with compare-and-swap updates, so the lease itself carries the count.
+func (e *Elector) stampEpoch(annotations map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	annotations[epochAnnotation] = strconv.FormatInt(e.lastEpoch+1, 10)
+}
+
+// setEpoch records the epoch of the lock this Elector holds.
+func (e *Elector) setEpoch(cm *corev1.ConfigMap) {
+	epoch := lockEpoch(cm)
+	epochGauge.WithLabelValues(e.name).Set(float64(epoch))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.epoch = epoch
+	if epoch > e.lastEpoch {
+		e.lastEpoch = epoch
+	}
+}
+
+// epochCounterName returns the name of the epoch counter of the named lock.
+// Names too long to take the suffix are truncated and suffixed with a hash,
+// like those made by SanitizeName, so that counters of different locks remain
+// distinct.
+func epochCounterName(name string) string {
+	max := validation.DNS1123SubdomainMaxLength - len(epochCounterSuffix)
+	if len(name) > max {
+		sum := sha256.Sum256([]byte(name))
+		hash := hex.EncodeToString(sum[:])[:hashSuffixLength]
+		name = strings.TrimRight(name[:max-len(hash)-1], "-.") + "-" + hash
+	}
+	return name + epochCounterSuffix
+}
+
+// readEpoch returns the epoch counter of a lock that is deleted each term, or
+// nil if there is none yet, and the epoch of the next term: one more than the
+// higher of the count and the highest epoch seen.
+func (e *Elector) readEpoch() (*corev1.ConfigMap, int64, error) {
+	counter, err := e.client.CoreV1().ConfigMaps(e.ns).Get(epochCounterName(e.name), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		counter = nil
+	case err != nil:
+		return nil, 0, e.permissionError(err, "get", "configmaps")
+	}
+
+	e.mu.Lock()
+	epoch := e.lastEpoch
+	e.mu.Unlock()
+	if counter != nil {
+		if count, err := strconv.ParseInt(counter.Data[epochKey], 10, 64); err == nil && count > epoch {
+			epoch = count
+		}
+	}
+	return counter, epoch + 1, nil
+}
+
+// claimEpoch advances the epoch counter to the provided epoch, that of the lock
+// just created by this Elector, where counter is the counter as read by
+// readEpoch before the lock was created. The counter is replaced rather than
+// updated: deleting it on the condition that it is unchanged lets only one
+// leader claim each count, with the permissions every election has anyway.
+// Because a term starts only once the previous lock is gone, each new leader
+// sees the claim of the one before, so epochs increase across leaders and
+// across pods that never saw earlier locks, and may serve as fencing tokens.
+// An error means that another leader may have claimed the same epoch.
+func (e *Elector) claimEpoch(counter *corev1.ConfigMap, epoch int64) error {
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	if counter != nil {
+		if err := cms.Delete(counter.Name, metav1.NewPreconditionDeleteOptions(string(counter.UID))); err != nil {
+			return e.permissionError(err, "delete", "configmaps")
+		}
+	}
+	_, err := cms.Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      epochCounterName(e.name),
+			Namespace: e.ns,
+			Labels:    map[string]string{epochCounterLabel: "true"},
+		},
+		Data: map[string]string{epochKey: strconv.FormatInt(epoch, 10)},
+	})
+	return e.permissionError(err, "create", "configmaps")
+}
+
+// abandonLock deletes a lock just created by this Elector, whose term can't
+// begin. Errors are logged and otherwise ignored; the lock is then taken over
+// like that of any leader that went away.
+func (e *Elector) abandonLock(created *corev1.ConfigMap) {
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	err := cms.Delete(created.Name, metav1.NewPreconditionDeleteOptions(string(created.UID)))
+	if err != nil && !apierrors.IsNotFound(err) {
+		e.log().Warnf("failed to delete lock: %s", err.Error())
+		return
+	}
+	if hasFinalizer(created) {
+		latest, err := cms.Get(created.Name, metav1.GetOptions{})
+		if err == nil && latest.UID == created.UID {
+			if err := dropFinalizer(cms.Update, latest); err != nil {
+				e.log().Warnf("failed to remove finalizer: %s", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package leader
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testCounter returns the epoch counter of the named lock with the provided
+// count.
+func testCounter(lock, count string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            epochCounterName(lock),
+			Namespace:       testNamespace,
+			UID:             "counter-uid",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{epochKey: count},
+	}
+}
+
+func TestEpochCounterName(t *testing.T) {
+	if got := epochCounterName("lock"); got != "lock.epoch" {
+		t.Errorf("counter of lock is %q, expected lock.epoch", got)
+	}
+	long := strings.Repeat("a", validation.DNS1123SubdomainMaxLength)
+	other := long[:len(long)-1] + "b"
+	for _, name := range []string{long, other} {
+		got := epochCounterName(name)
+		if err := ValidateName(got); err != nil {
+			t.Errorf("counter of a lock named with %d characters is invalid: %s", len(name), err.Error())
+		}
+		if !strings.HasSuffix(got, epochCounterSuffix) {
+			t.Errorf("counter %q does not end with %q", got, epochCounterSuffix)
+		}
+	}
+	if epochCounterName(long) == epochCounterName(other) {
+		t.Error("long locks that differ share a counter")
+	}
+
+	if err := NewElector("lock.epoch").Validate(); err == nil {
+		t.Error("a lock named like a counter is valid")
+	}
+}
+
+func TestBecomeRecordsEpoch(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testCounter("lock", "6"))
+	// observe the lock as it is created
+	var created *corev1.ConfigMap
+	cluster.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if cm := action.(k8stesting.CreateAction).GetObject().(*corev1.ConfigMap); cm.Name == "lock" {
+			created = cm
+		}
+		return false, nil, nil
+	})
+	e := newTestElector("lock", cluster, clock)
+
+	if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if got := lockEpoch(created); got != 7 {
+		t.Errorf("lock was created with epoch %d, expected 7", got)
+	}
+	counter := cluster.lock(t, epochCounterName("lock"))
+	if counter.Data[epochKey] != "7" || counter.Labels[epochCounterLabel] != "true" {
+		t.Errorf("counter is %v labelled %v, expected 7 labelled as a counter", counter.Data, counter.Labels)
+	}
+}
+
+func TestBecomeEpochClaimed(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testCounter("lock", "6"))
+	// another leader claims 7 between pod-a reading the counter and
+	// claiming the same epoch
+	var claim sync.Once
+	cluster.PrependReactor("delete", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.DeleteAction).GetName()
+		if name != epochCounterName("lock") {
+			return false, nil, nil
+		}
+		claimed := false
+		claim.Do(func() { claimed = true })
+		if !claimed {
+			return false, nil, nil
+		}
+		if err := cluster.tracker.Delete(configMapsResource, testNamespace, name); err != nil {
+			return true, nil, err
+		}
+		return true, nil, cluster.tracker.Add(testCounter("lock", "7"))
+	})
+	e := newTestElector("lock", cluster, clock)
+
+	if err := await(t, clock, time.Second, async(func() error { return e.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if got := e.Status().Epoch; got != 8 {
+		t.Errorf("epoch is %d, expected 8", got)
+	}
+	cm := cluster.lock(t, "lock")
+	if cm.UID != "uid-2" || lockEpoch(cm) != 8 {
+		t.Errorf("lock %s records epoch %d, expected a second lock with epoch 8", cm.UID, lockEpoch(cm))
+	}
+	if got := cluster.lock(t, epochCounterName("lock")).Data[epochKey]; got != "8" {
+		t.Errorf("counter is %s, expected 8", got)
+	}
+}
+
+func TestBecomeEpochForbidden(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	cluster.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if cm := action.(k8stesting.CreateAction).GetObject().(*corev1.ConfigMap); cm.Name != epochCounterName("lock") {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(configMapsResource.GroupResource(), epochCounterName("lock"), nil)
+	})
+	e := newTestElector("lock", cluster, clock)
+
+	err := await(t, clock, time.Second, async(func() error { return e.Become(context.Background()) }))
+	if _, ok := err.(*PermissionError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cm := cluster.lock(t, "lock"); cm != nil {
+		t.Errorf("lock still exists, held by %q", lockHolder(cm))
+	}
+	if state := e.Status().State; state == StateLeader {
+		t.Error("became the leader without an epoch")
+	}
+}
@@ -0,0 +1,380 @@
+package leader
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testNamespace is the namespace of every object in these tests.
+const testNamespace = "test"
+
+// testTimeout is how long, in real time, a test waits for something to happen.
+const testTimeout = time.Second * 10
+
+// fakeClock implements Clock and Sleeper. Time stands still until Step is
+// called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Step advances the clock by d and fires the waiters that are then due.
+func (c *fakeClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// fakeCluster is a fake clientset whose ConfigMaps behave as the API server's
+// do where elections depend on it: they get UIDs, creation timestamps and
+// resource versions, and updates with a stale resourceVersion conflict.
+type fakeCluster struct {
+	*fake.Clientset
+	tracker k8stesting.ObjectTracker
+	clock   Clock
+
+	mu   sync.Mutex
+	uids int
+	// unreachable fails every request for ConfigMaps while set
+	unreachable bool
+	// watches is how many watches have been started
+	watches int
+}
+
+var configMapsResource = corev1.SchemeGroupVersion.WithResource("configmaps")
+
+func newFakeCluster(clock Clock, objs ...runtime.Object) *fakeCluster {
+	c := &fakeCluster{
+		Clientset: fake.NewSimpleClientset(),
+		tracker:   k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder()),
+		clock:     clock,
+	}
+	for _, obj := range objs {
+		if err := c.tracker.Add(obj); err != nil {
+			panic(err)
+		}
+	}
+	c.PrependReactor("*", "*", k8stesting.ObjectReaction(c.tracker))
+	// access reviews are answered, not stored, and deny everything; see allow
+	c.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, action.(k8stesting.CreateAction).GetObject(), nil
+	})
+	c.PrependReactor("create", "configmaps", c.createConfigMap)
+	c.PrependReactor("update", "configmaps", c.updateConfigMap)
+	c.PrependReactor("list", "configmaps", c.listConfigMaps)
+	c.PrependReactor("*", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.unreachable {
+			return true, nil, errors.New("connection refused")
+		}
+		return false, nil, nil
+	})
+	c.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := c.tracker.Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		c.mu.Lock()
+		c.watches++
+		c.mu.Unlock()
+		selector := action.(k8stesting.WatchAction).GetWatchRestrictions().Fields
+		return true, watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+			cm, ok := event.Object.(*corev1.ConfigMap)
+			return event, !ok || matchesName(selector, cm)
+		}), nil
+	})
+	return c
+}
+
+func (c *fakeCluster) createConfigMap(action k8stesting.Action) (bool, runtime.Object, error) {
+	cm := action.(k8stesting.CreateAction).GetObject().(*corev1.ConfigMap)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cm.UID = types.UID("uid-" + strconv.Itoa(c.uids+1))
+	cm.CreationTimestamp = metav1.NewTime(c.clock.Now())
+	cm.ResourceVersion = "1"
+	if err := c.tracker.Create(configMapsResource, cm, action.GetNamespace()); err != nil {
+		return true, nil, err
+	}
+	c.uids++
+	return true, cm, nil
+}
+
+func (c *fakeCluster) updateConfigMap(action k8stesting.Action) (bool, runtime.Object, error) {
+	cm := action.(k8stesting.UpdateAction).GetObject().(*corev1.ConfigMap)
+	existing, err := c.configMap(cm.Name)
+	if err != nil {
+		return true, nil, err
+	}
+	if cm.ResourceVersion != "" && cm.ResourceVersion != existing.ResourceVersion {
+		return true, nil, apierrors.NewConflict(configMapsResource.GroupResource(), cm.Name, nil)
+	}
+	version, _ := strconv.Atoi(existing.ResourceVersion)
+	cm.ResourceVersion = strconv.Itoa(version + 1)
+	cm.UID = existing.UID
+	cm.CreationTimestamp = existing.CreationTimestamp
+	if err := c.tracker.Update(configMapsResource, cm, action.GetNamespace()); err != nil {
+		return true, nil, err
+	}
+	return true, cm, nil
+}
+
+// listConfigMaps lists ConfigMaps as the API server does, honouring the
+// field selector that the fake clientset ignores.
+func (c *fakeCluster) listConfigMaps(action k8stesting.Action) (bool, runtime.Object, error) {
+	obj, err := c.tracker.List(configMapsResource, corev1.SchemeGroupVersion.WithKind("ConfigMap"), action.GetNamespace())
+	if err != nil {
+		return true, nil, err
+	}
+	list := obj.(*corev1.ConfigMapList)
+	selector := action.(k8stesting.ListAction).GetListRestrictions().Fields
+	items := list.Items[:0]
+	for _, cm := range list.Items {
+		if matchesName(selector, &cm) {
+			items = append(items, cm)
+		}
+	}
+	list.Items = items
+	return true, list, nil
+}
+
+// matchesName reports whether cm matches a field selector on metadata.name.
+func matchesName(selector fields.Selector, cm *corev1.ConfigMap) bool {
+	return selector == nil || selector.Matches(fields.Set{"metadata.name": cm.Name})
+}
+
+// setUnreachable makes requests for ConfigMaps fail, or succeed again.
+func (c *fakeCluster) setUnreachable(unreachable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unreachable = unreachable
+}
+
+// watching returns how many watches have been started. The fake watches do
+// not replay what happened before they started, so tests wait for a watch
+// before changing what it reports.
+func (c *fakeCluster) watching() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.watches
+}
+
+// configMap returns the named ConfigMap, bypassing the reactors.
+func (c *fakeCluster) configMap(name string) (*corev1.ConfigMap, error) {
+	obj, err := c.tracker.Get(configMapsResource, testNamespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*corev1.ConfigMap), nil
+}
+
+// lock returns the named lock, or nil if it does not exist.
+func (c *fakeCluster) lock(t *testing.T, name string) *corev1.ConfigMap {
+	t.Helper()
+	cm, err := c.configMap(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("failed to get lock %s: %s", name, err.Error())
+	}
+	return cm
+}
+
+// modify changes the named ConfigMap with fn, as another process would.
+func (c *fakeCluster) modify(t *testing.T, name string, fn func(cm *corev1.ConfigMap)) {
+	t.Helper()
+	cm, err := c.configMap(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm = cm.DeepCopy()
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	fn(cm)
+	version, _ := strconv.Atoi(cm.ResourceVersion)
+	cm.ResourceVersion = strconv.Itoa(version + 1)
+	if err := c.tracker.Update(configMapsResource, cm, testNamespace); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// deletePod deletes the named pod, and the locks it owns as the garbage
+// collector would.
+func (c *fakeCluster) deletePod(t *testing.T, name string) {
+	t.Helper()
+	objs, err := c.tracker.List(configMapsResource, corev1.SchemeGroupVersion.WithKind("ConfigMap"), testNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cm := range objs.(*corev1.ConfigMapList).Items {
+		for _, ref := range cm.OwnerReferences {
+			if ref.Kind == "Pod" && ref.Name == name {
+				if err := c.tracker.Delete(configMapsResource, testNamespace, cm.Name); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if err := c.tracker.Delete(corev1.SchemeGroupVersion.WithResource("pods"), testNamespace, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testPod returns a running pod with the provided name.
+func testPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			UID:       types.UID(name + "-uid"),
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// testLock returns a lock called name, held and owned by the named pod, as
+// created by an earlier process.
+func testLock(name, pod string, annotations map[string]string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       testNamespace,
+			UID:             types.UID(name + "-" + pod),
+			ResourceVersion: "1",
+			Labels:          map[string]string{LockLabel: "true"},
+			Annotations:     map[string]string{holderAnnotation: pod},
+			OwnerReferences: []metav1.OwnerReference{podOwnerRef(testPod(pod))},
+		},
+	}
+	for k, v := range annotations {
+		cm.Annotations[k] = v
+	}
+	return cm
+}
+
+// newTestElector returns an Elector for the current pod, "pod-a", that uses
+// the provided cluster and clock.
+func newTestElector(name string, cluster *fakeCluster, clock *fakeClock, opts ...Option) *Elector {
+	return NewElector(name, append(testOptions(cluster, clock), opts...)...)
+}
+
+// testOptions returns the options of newTestElector, for the constructors of
+// Semaphore and Group.
+func testOptions(cluster *fakeCluster, clock *fakeClock) []Option {
+	return []Option{
+		WithClient(cluster),
+		WithNamespace(testNamespace),
+		WithClock(clock),
+		WithSleeper(clock),
+		WithHostname("pod-a"),
+		WithoutPreflight(),
+	}
+}
+
+// async runs fn in a goroutine and returns a channel that receives its error.
+func async(fn func() error) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- fn()
+	}()
+	return ch
+}
+
+// await waits for ch to receive, advancing clock by step each time it checks,
+// and returns what it received.
+func await(t *testing.T, clock *fakeClock, step time.Duration, ch <-chan error) error {
+	t.Helper()
+	var err error
+	eventually(t, clock, step, func() bool {
+		select {
+		case err = <-ch:
+			return true
+		default:
+			return false
+		}
+	})
+	return err
+}
+
+// eventually waits for cond to return true, advancing clock by step each time
+// it checks. The test fails if that takes longer than testTimeout.
+func eventually(t *testing.T, clock *fakeClock, step time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond * 5)
+		clock.Step(step)
+	}
+}
+
+// never checks that ch does not receive while clock is advanced by step a few
+// times.
+func never(t *testing.T, clock *fakeClock, step time.Duration, ch <-chan error) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Millisecond * 5)
+		select {
+		case err := <-ch:
+			t.Fatalf("returned early: %v", err)
+		default:
+		}
+		clock.Step(step)
+	}
+}
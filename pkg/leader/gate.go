@@ -0,0 +1,83 @@
+package leader
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate reflects whether an Elector currently holds leadership. It is safe for
+// use from many goroutines, and is useful for code that can't be structured
+// around the callbacks used by Run.
+type Gate struct {
+	mu      sync.Mutex
+	leading bool
+	// acquired is closed while leadership is held
+	acquired chan struct{}
+	// lost is closed when the current (or next) term of leadership ends
+	lost chan struct{}
+}
+
+func newGate() *Gate {
+	return &Gate{
+		acquired: make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+}
+
+// Gate returns the Gate tracking this Elector's leadership.
+func (e *Elector) Gate() *Gate {
+	return e.gate
+}
+
+// Leading returns true if leadership is currently held.
+func (g *Gate) Leading() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.leading
+}
+
+// Wait blocks until leadership is held or ctx is done, in which case it
+// returns ctx.Err().
+func (g *Gate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	acquired := g.acquired
+	g.mu.Unlock()
+
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel that is closed when the current term of leadership
+// ends. If leadership is not held, the channel belongs to the next term, so a
+// caller can Wait and then watch Done without missing a loss in between.
+func (g *Gate) Done() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lost
+}
+
+func (g *Gate) open() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.leading {
+		return
+	}
+	g.leading = true
+	close(g.acquired)
+}
+
+func (g *Gate) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.leading {
+		return
+	}
+	g.leading = false
+	close(g.lost)
+	g.acquired = make(chan struct{})
+	g.lost = make(chan struct{})
+}
@@ -0,0 +1,133 @@
+package leader
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// groupRetryDelay is how long a Group waits, after giving back the locks it
+// acquired, before trying the whole group again.
+const groupRetryDelay = time.Second * 1
+
+// Group acquires several locks as a unit, for leadership that spans several
+// scopes. Locks are acquired one at a time in order of their names, so that
+// groups with overlapping locks do not deadlock. If a lock can't be acquired
+// within the Group's bound, the locks already held are released and the whole
+// group is tried again, so a Group never settles for partial leadership.
+type Group struct {
+	electors []*Elector
+	bound    time.Duration
+}
+
+// NewGroup returns a Group for the locks with the provided names. Each lock
+// must be acquired within bound. The options apply to the Elector of every
+// lock.
+func NewGroup(names []string, bound time.Duration, opts ...Option) *Group {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	g := &Group{bound: bound}
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			continue
+		}
+		g.electors = append(g.electors, NewElector(name, opts...))
+	}
+	return g
+}
+
+// Electors returns the Electors of the group's locks, in the order they are
+// acquired.
+func (g *Group) Electors() []*Elector {
+	return append([]*Elector{}, g.electors...)
+}
+
+// Leading returns true if every lock in the group is held.
+func (g *Group) Leading() bool {
+	for _, e := range g.electors {
+		if e.Status().State != StateLeader {
+			return false
+		}
+	}
+	return true
+}
+
+// Become blocks until every lock in the group is held, or ctx is done. When ctx
+// is done, any locks acquired so far are released.
+func (g *Group) Become(ctx context.Context) error {
+	if len(g.electors) == 0 {
+		return nil
+	}
+	for {
+		err := g.tryAll(ctx)
+		if err == nil {
+			return nil
+		}
+		g.releaseHeld()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != context.DeadlineExceeded {
+			return err
+		}
+
+		select {
+		case <-g.electors[0].sleeper.After(groupRetryDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// tryAll acquires each lock in order, giving up at the first that is not
+// acquired within the bound.
+func (g *Group) tryAll(ctx context.Context) error {
+	for _, e := range g.electors {
+		if e.Status().State == StateLeader {
+			continue
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, g.bound)
+		err := e.becomeUntil(attemptCtx, ctx)
+		cancel()
+		if err != nil {
+			if err == context.DeadlineExceeded {
+				e.log().Infof("Lock was not acquired within %s; releasing the rest of the group.", g.bound)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Release releases every lock in the group that is held, and returns the first
+// error encountered.
+func (g *Group) Release(ctx context.Context) error {
+	var first error
+	for i := len(g.electors) - 1; i >= 0; i-- {
+		e := g.electors[i]
+		if e.Status().State != StateLeader {
+			continue
+		}
+		if err := e.Release(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// releaseHeld gives back the locks acquired during a failed attempt. Errors
+// are logged and otherwise ignored. Each release ends the attempt's term of
+// leadership, which stops the goroutine maintaining it; like any release, it
+// is not a loss.
+func (g *Group) releaseHeld() {
+	for i := len(g.electors) - 1; i >= 0; i-- {
+		e := g.electors[i]
+		if e.Status().State != StateLeader {
+			continue
+		}
+		if err := e.release(context.Background()); err != nil {
+			e.log().Warnf("failed to release lock: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package leader
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGroupBecome(t *testing.T) {
+	tests := []struct {
+		name    string
+		objs    []runtime.Object
+		bound   time.Duration
+		timeout time.Duration
+		// free is set if pod-b, and with it its lock, goes away once the
+		// group is waiting
+		free     bool
+		wantErr  error
+		wantHeld string
+	}{
+		{
+			name:     "all free",
+			bound:    testTimeout,
+			timeout:  testTimeout,
+			wantHeld: "a,b",
+		},
+		{
+			name:     "one freed within the bound",
+			objs:     []runtime.Object{testPod("pod-b"), testLock("b", "pod-b", nil)},
+			bound:    testTimeout,
+			timeout:  testTimeout,
+			free:     true,
+			wantHeld: "a,b",
+		},
+		{
+			name:    "one held past the bound",
+			objs:    []runtime.Object{testPod("pod-b"), testLock("b", "pod-b", nil)},
+			bound:   time.Millisecond * 50,
+			timeout: time.Millisecond * 500,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, append(tc.objs, testPod("pod-a"))...)
+			g := NewGroup([]string{"b", "a", "b"}, tc.bound, testOptions(cluster, clock)...)
+			if n := len(g.Electors()); n != 2 {
+				t.Fatalf("group has %d locks, expected 2", n)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			result := async(func() error { return g.Become(ctx) })
+			if tc.free {
+				never(t, clock, maxBackoff, result)
+				cluster.deletePod(t, "pod-b")
+			}
+			if err := await(t, clock, 0, result); err != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if leading := g.Leading(); leading != (tc.wantErr == nil) {
+				t.Errorf("group leading: %t", leading)
+			}
+			var held []string
+			for _, name := range []string{"a", "b"} {
+				if cm := cluster.lock(t, name); cm != nil && lockHolder(cm) == "pod-a" {
+					held = append(held, name)
+				}
+			}
+			if got := strings.Join(held, ","); got != tc.wantHeld {
+				t.Errorf("pod-a holds %q, expected %q", got, tc.wantHeld)
+			}
+		})
+	}
+}
+
+func TestGroupRelease(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	g := NewGroup([]string{"a", "b"}, testTimeout, testOptions(cluster, clock)...)
+	if err := g.Become(context.Background()); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if err := g.Release(context.Background()); err != nil {
+		t.Fatalf("Release failed: %s", err.Error())
+	}
+	for _, name := range []string{"a", "b"} {
+		if cluster.lock(t, name) != nil {
+			t.Errorf("lock %s still exists", name)
+		}
+	}
+	if g.Leading() {
+		t.Error("group still leading")
+	}
+}
+
+func TestGroupRetry(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), testLock("b", "pod-b", nil))
+	var mu sync.Mutex
+	losses := 0
+	opts := append(testOptions(cluster, clock),
+		WithLockTTL(time.Minute),
+		WithReleaseOnCancel(),
+		WithLossPolicy(func(string) {
+			mu.Lock()
+			defer mu.Unlock()
+			losses++
+		}),
+	)
+	g := NewGroup([]string{"a", "b"}, time.Millisecond*20, opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return g.Become(ctx) })
+	// a is acquired and given back on each attempt
+	eventually(t, clock, groupRetryDelay, func() bool { return g.Electors()[0].Status().Attempts >= 3 })
+	cluster.deletePod(t, "pod-b")
+	if err := await(t, clock, groupRetryDelay, result); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+
+	// the goroutines maintaining earlier terms of a leave the current one alone
+	never(t, clock, lockCheckInterval, make(chan error))
+	if !g.Leading() {
+		t.Error("group is not leading")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if losses != 0 {
+		t.Errorf("giving back locks counted as %d losses", losses)
+	}
+}
@@ -0,0 +1,82 @@
+package leader
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Handoff is a summary of the previous leader's work, such as the last
+// checkpoint it processed, as published with UpdateLeaderData. A new leader
+// can use it to resume where the previous one left off instead of starting
+// with a full resync.
+type Handoff struct {
+	// From is the identity of the previous leader. It is this Elector's own
+	// identity when leadership was resumed after a restart.
+	From string
+	// Epoch is the epoch of the previous leader's term.
+	Epoch int64
+	// Data is the data the previous leader published on the lock.
+	Data map[string]string
+}
+
+type handoffKey struct{}
+
+// HandoffFromContext returns the Handoff received for the term of leadership
+// whose context is provided, as passed to the function run by Run and
+// BecomeAndRun. It returns false if the previous leader left no data.
+func HandoffFromContext(ctx context.Context) (Handoff, bool) {
+	h, ok := ctx.Value(handoffKey{}).(*Handoff)
+	if !ok || h == nil {
+		return Handoff{}, false
+	}
+	return *h, true
+}
+
+// Handoff returns the Handoff received for the current or most recent term of
+// leadership. It returns false if the previous leader left no data.
+//
+// In lease mode the lock outlives each leader, so the data is exactly as the
+// previous leader left it. Otherwise the lock is deleted when leadership ends,
+// and the data is as this Elector last saw it while waiting; a leader that
+// publishes a checkpoint regularly keeps the handoff close to current.
+func (e *Elector) Handoff() (Handoff, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.handoff == nil {
+		return Handoff{}, false
+	}
+	return *e.handoff, true
+}
+
+// observeHandoff records the data published on a lock seen by this Elector, to
+// hand off if it becomes the next leader. A lock without data clears what was
+// seen before, since it belongs to a later leader.
+func (e *Elector) observeHandoff(cm *corev1.ConfigMap) {
+	var h *Handoff
+	if data := leaderData(cm); len(data) > 0 {
+		h = &Handoff{
+			From:  lockHolder(cm),
+			Epoch: lockEpoch(cm),
+			Data:  data,
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pendingHandoff = h
+}
+
+// takeHandoffLocked makes the most recently observed data the handoff for a new
+// term of leadership. e.mu must be held.
+func (e *Elector) takeHandoffLocked() {
+	e.handoff = e.pendingHandoff
+	e.pendingHandoff = nil
+}
+
+// handoffContext returns a copy of ctx carrying the handoff for the current
+// term of leadership, for HandoffFromContext.
+func (e *Elector) handoffContext(ctx context.Context) context.Context {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return context.WithValue(ctx, handoffKey{}, e.handoff)
+}
@@ -0,0 +1,116 @@
+package leader
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandoff(t *testing.T) {
+	completed := testPod("pod-b")
+	completed.Status.Phase = corev1.PodSucceeded
+	withData := func(cm *corev1.ConfigMap) *corev1.ConfigMap {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data["checkpoint"] = "7"
+		return cm
+	}
+	data := map[string]string{"checkpoint": "7"}
+
+	tests := []struct {
+		name        string
+		objs        []runtime.Object
+		opts        []Option
+		wantHandoff *Handoff
+	}{
+		{
+			name: "no previous leader",
+		},
+		{
+			name: "previous leader left no data",
+			objs: []runtime.Object{completed, testLock("lock", "pod-b", map[string]string{epochAnnotation: "2"})},
+		},
+		{
+			name:        "data of a completed leader",
+			objs:        []runtime.Object{completed, withData(testLock("lock", "pod-b", map[string]string{epochAnnotation: "2"}))},
+			wantHandoff: &Handoff{From: "pod-b", Epoch: 2, Data: data},
+		},
+		{
+			name:        "own lock is resumed",
+			objs:        []runtime.Object{withData(testLock("lock", "pod-a", map[string]string{epochAnnotation: "2"}))},
+			wantHandoff: &Handoff{From: "pod-a", Epoch: 2, Data: data},
+		},
+		{
+			name:        "lease taken over",
+			objs:        []runtime.Object{withData(testLease("lock", "pod-b", "5"))},
+			opts:        []Option{WithLease(testLeaseDuration)},
+			wantHandoff: &Handoff{From: "pod-b", Epoch: 5, Data: data},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, append(tc.objs, testPod("pod-a"))...)
+			e := newTestElector("lock", cluster, clock, tc.opts...)
+
+			var fromContext *Handoff
+			err := await(t, clock, time.Second, async(func() error {
+				return e.BecomeAndRun(context.Background(), func(ctx context.Context) error {
+					if h, ok := HandoffFromContext(ctx); ok {
+						fromContext = &h
+					}
+					return nil
+				})
+			}))
+			if err != nil {
+				t.Fatalf("BecomeAndRun failed: %s", err.Error())
+			}
+
+			var got *Handoff
+			if h, ok := e.Handoff(); ok {
+				got = &h
+			}
+			if !reflect.DeepEqual(got, tc.wantHandoff) {
+				t.Errorf("handoff is %+v, expected %+v", got, tc.wantHandoff)
+			}
+			if !reflect.DeepEqual(fromContext, tc.wantHandoff) {
+				t.Errorf("handoff in the context is %+v, expected %+v", fromContext, tc.wantHandoff)
+			}
+		})
+	}
+}
+
+func TestHandoffCleared(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), testPod("pod-c"))
+	leader := testLock("lock", "pod-b", nil)
+	leader.Data = map[string]string{"checkpoint": "7"}
+	if err := cluster.tracker.Add(leader); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestElector("lock", cluster, clock)
+
+	result := async(func() error { return e.Become(context.Background()) })
+	never(t, clock, maxBackoff, result)
+	// a later leader that publishes nothing replaces the one that did
+	cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+		*cm = *testLock("lock", "pod-c", nil)
+	})
+	never(t, clock, maxBackoff, result)
+	if got := e.Status().Holder; got != "pod-c" {
+		t.Errorf("waiting on holder %q, expected pod-c", got)
+	}
+	cluster.deletePod(t, "pod-c")
+	if err := await(t, clock, maxBackoff, result); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if h, ok := e.Handoff(); ok {
+		t.Errorf("handoff is %+v, expected none", h)
+	}
+}
@@ -0,0 +1,149 @@
+package leader
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// hookQueueSize is how many hook calls may be pending before further ones are
+// dropped, or for transitions, wait for room.
+const hookQueueSize = 100
+
+// Hooks are optional functions called at each stage of an election, for custom
+// metrics, logging or other side effects. Any of them may be nil. Hooks are
+// called one at a time, in order, from a goroutine separate from the election,
+// so a slow hook does not hold up the election; if hooks fall too far behind,
+// further OnAttempt and OnError calls are dropped, while the election waits
+// for room to queue OnAcquired and OnLost, so that no transition is missed.
+type Hooks struct {
+	// OnAttempt is called each time creation of the lock is attempted, with
+	// the number of attempts made so far.
+	OnAttempt func(lock string, attempt int)
+	// OnAcquired is called when leadership is acquired.
+	OnAcquired func(lock string)
+	// OnLost is called when leadership ends, whether it was lost or released.
+	OnLost func(lock string)
+	// OnError is called when the election encounters an error.
+	OnError func(lock string, err error)
+	// OnStop is called with a summary of the election when Become, Run,
+	// BecomeAndRun or Runner.Run returns, after any hooks still queued. Those
+	// wait for it to return, so it is never dropped.
+	OnStop func(lock string, report Report)
+}
+
+// hookRunner calls hooks from a single goroutine, started on first use, and
+// again on the first use after stop.
+type hookRunner struct {
+	mu sync.Mutex
+	q  *hookQueue
+}
+
+// hookQueue is the queue of one hookRunner goroutine. senders counts the calls
+// being queued, which stop waits for before closing calls.
+type hookQueue struct {
+	calls   chan func()
+	done    chan struct{}
+	senders sync.WaitGroup
+}
+
+// queue returns the queue, starting its goroutine if it is not running, and
+// counts the caller as a sender until it calls q.senders.Done.
+func (r *hookRunner) queue() *hookQueue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.q == nil {
+		q := &hookQueue{calls: make(chan func(), hookQueueSize), done: make(chan struct{})}
+		go func() {
+			defer close(q.done)
+			for c := range q.calls {
+				c()
+			}
+		}()
+		r.q = q
+	}
+	r.q.senders.Add(1)
+	return r.q
+}
+
+func (r *hookRunner) run(lock string, call func()) {
+	q := r.queue()
+	defer q.senders.Done()
+	select {
+	case q.calls <- call:
+	default:
+		logrus.WithField("lock", lock).Warn("hooks are falling behind; dropping a call")
+	}
+}
+
+// runTransition queues the calls for a change of leadership. Unlike run, it
+// waits for room rather than dropping them. It must not be called with e.mu
+// held, since hooks may need it.
+func (r *hookRunner) runTransition(t transition) {
+	if len(t) == 0 {
+		return
+	}
+	q := r.queue()
+	defer q.senders.Done()
+	for _, call := range t {
+		q.calls <- call
+	}
+}
+
+// runAndWait calls call once the calls already queued have returned, and
+// waits for it to return. Unlike run, it never drops the call.
+func (r *hookRunner) runAndWait(call func()) {
+	done := make(chan struct{})
+	r.runTransition(transition{func() {
+		defer close(done)
+		call()
+	}})
+	<-done
+}
+
+// stop waits for the calls already queued to return, and then stops the
+// goroutine, so that an election that has ended leaves none behind.
+func (r *hookRunner) stop() {
+	r.mu.Lock()
+	q := r.q
+	r.q = nil
+	r.mu.Unlock()
+	if q == nil {
+		return
+	}
+	q.senders.Wait()
+	close(q.calls)
+	<-q.done
+}
+
+// transition collects the calls for a change of leadership while e.mu is
+// held, to be queued with runTransition once it is released.
+type transition []func()
+
+func (t *transition) add(call func()) {
+	*t = append(*t, call)
+}
+
+func (e *Elector) onAttempt(attempt int) {
+	if f := e.hooks.OnAttempt; f != nil {
+		e.hookRunner.run(e.name, func() { f(e.name, attempt) })
+	}
+}
+
+func (e *Elector) onAcquired(t *transition) {
+	if f := e.hooks.OnAcquired; f != nil {
+		t.add(func() { f(e.name) })
+	}
+}
+
+func (e *Elector) onLost(t *transition) {
+	if f := e.hooks.OnLost; f != nil {
+		t.add(func() { f(e.name) })
+	}
+}
+
+func (e *Elector) onError(err error) {
+	if f := e.hooks.OnError; f != nil {
+		e.hookRunner.run(e.name, func() { f(e.name, err) })
+	}
+}
@@ -0,0 +1,97 @@
+package leader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHookRunnerStop(t *testing.T) {
+	var r hookRunner
+	ran := make(chan string, 2)
+	r.run("lock", func() { ran <- "first" })
+	r.stop()
+	if len(ran) != 1 {
+		t.Fatal("stop returned before the queued call")
+	}
+	if r.q != nil {
+		t.Error("runner still has a queue")
+	}
+
+	// a later call starts it again
+	r.run("lock", func() { ran <- "second" })
+	r.stop()
+	if len(ran) != 2 {
+		t.Error("call after stop was not made")
+	}
+}
+
+func TestTransitionHooksWait(t *testing.T) {
+	var mu sync.Mutex
+	acquired := 0
+	e := NewElector("lock", WithHooks(Hooks{OnAcquired: func(string) {
+		mu.Lock()
+		defer mu.Unlock()
+		acquired++
+	}}))
+	defer e.hookRunner.stop()
+
+	// fill the queue behind a call that blocks
+	started, block := make(chan struct{}), make(chan struct{})
+	var unblock sync.Once
+	defer unblock.Do(func() { close(block) })
+	e.hookRunner.run("lock", func() {
+		close(started)
+		<-block
+	})
+	<-started
+	for i := 0; i < hookQueueSize; i++ {
+		e.hookRunner.run("lock", func() {})
+	}
+
+	result := async(func() error {
+		e.setLeader("pod-a", time.Now())
+		return nil
+	})
+	select {
+	case <-result:
+		t.Fatal("transition did not wait for room in the queue")
+	case <-time.After(time.Millisecond * 50):
+	}
+	unblock.Do(func() { close(block) })
+	<-result
+
+	e.hookRunner.stop()
+	mu.Lock()
+	defer mu.Unlock()
+	if acquired != 1 {
+		t.Errorf("OnAcquired was called %d times, expected 1", acquired)
+	}
+}
+
+func TestBecomeReport(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	reports := make(chan Report, 1)
+	e := newTestElector("lock", cluster, clock, WithHooks(Hooks{OnStop: func(lock string, r Report) {
+		reports <- r
+	}}))
+
+	if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	select {
+	case r := <-reports:
+		if r.FinalState != StateLeader || r.Transitions != 1 {
+			t.Errorf("report is %+v, expected a leader after one transition", r)
+		}
+	default:
+		t.Fatal("Become returned without a report")
+	}
+	e.hookRunner.mu.Lock()
+	defer e.hookRunner.mu.Unlock()
+	if e.hookRunner.q != nil {
+		t.Error("hook goroutine is still running")
+	}
+}
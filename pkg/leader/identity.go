@@ -0,0 +1,158 @@
+package leader
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultPodNameEnv is the environment variable EnvIdentity reads the pod name
+// from by default. It matches the name commonly used when exposing
+// metadata.name with the downward API.
+const DefaultPodNameEnv = "POD_NAME"
+
+// Identity is who an Elector holds its lock as.
+type Identity struct {
+	// Name is the identity the lock is held under. It must be unique among
+	// candidates.
+	Name string
+	// Owner is the object that owns the lock, so that the garbage collector
+	// deletes the lock along with it. It is empty for identities with nothing
+	// in the cluster to own the lock, which must be combined with WithLease.
+	Owner metav1.OwnerReference
+	// Pod is the current pod, if known. Options that act on the current pod,
+	// such as WithPeerPort, WithOrdinalPreference and WithJobOwner, need it.
+	Pod *corev1.Pod
+}
+
+// IdentityProvider determines the Identity of an Elector, once its client and
+// namespace are known. Providers let an election run where the built-in
+// lookups do not fit, such as on a virtual kubelet, without changes to the
+// election itself.
+type IdentityProvider interface {
+	Identity(client k8sclient.Interface, namespace string) (Identity, error)
+}
+
+// IdentityProviderFunc adapts a function to an IdentityProvider.
+type IdentityProviderFunc func(client k8sclient.Interface, namespace string) (Identity, error)
+
+// Identity calls f.
+func (f IdentityProviderFunc) Identity(client k8sclient.Interface, namespace string) (Identity, error) {
+	return f(client, namespace)
+}
+
+// podIdentity returns the Identity of the provided pod, which owns the lock.
+func podIdentity(pod *corev1.Pod) Identity {
+	return Identity{Name: pod.Name, Owner: podOwnerRef(pod), Pod: pod}
+}
+
+// getPod returns the named pod as an Identity.
+func getPod(client k8sclient.Interface, namespace, name string) (Identity, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return Identity{}, err
+	}
+	return podIdentity(pod), nil
+}
+
+type hostnameIdentity struct {
+	log         logrus.FieldLogger
+	hostname    string
+	podNameFile string
+}
+
+// HostnameIdentity returns a provider that finds the current pod by hostname,
+// which is taken from the OS if empty, and by the hostname's first label if it
+// is fully qualified. If no pod has that name and podNameFile is not empty, the
+// pod name is read from that file instead, as written by a downward API volume.
+// This is the default, configured with WithHostname and WithPodNameFile.
+func HostnameIdentity(hostname, podNameFile string) IdentityProvider {
+	return hostnameIdentity{
+		log:         logrus.StandardLogger(),
+		hostname:    hostname,
+		podNameFile: podNameFile,
+	}
+}
+
+func (p hostnameIdentity) Identity(client k8sclient.Interface, namespace string) (Identity, error) {
+	pod, err := myPod(p.log, client, namespace, p.hostname, p.podNameFile)
+	if err != nil {
+		return Identity{}, err
+	}
+	return podIdentity(pod), nil
+}
+
+// EnvIdentity returns a provider that finds the current pod by the name in the
+// environment variable key, as set with the downward API from metadata.name.
+// An empty key means DefaultPodNameEnv.
+func EnvIdentity(key string) IdentityProvider {
+	if key == "" {
+		key = DefaultPodNameEnv
+	}
+	return IdentityProviderFunc(func(client k8sclient.Interface, namespace string) (Identity, error) {
+		name := os.Getenv(key)
+		if name == "" {
+			return Identity{}, fmt.Errorf("environment variable %s is not set", key)
+		}
+		return getPod(client, namespace, name)
+	})
+}
+
+// StatefulSetIdentity returns a provider that finds the pod with the provided
+// ordinal in the named StatefulSet. Since StatefulSet pod names are stable, so
+// is the identity, wherever the ordinal comes from.
+func StatefulSetIdentity(statefulSet string, ordinal int) IdentityProvider {
+	return IdentityProviderFunc(func(client k8sclient.Interface, namespace string) (Identity, error) {
+		return getPod(client, namespace, statefulSet+"-"+strconv.Itoa(ordinal))
+	})
+}
+
+type staticIdentity struct {
+	identity Identity
+}
+
+// StaticIdentity returns a provider of a fixed identity and owner, with no
+// lookup. An empty owner suits processes that do not run in a pod, such as VMs,
+// and must be combined with WithLease. WithOwner and WithIdentity use it.
+func StaticIdentity(identity string, owner metav1.OwnerReference) IdentityProvider {
+	return staticIdentity{identity: Identity{Name: identity, Owner: owner}}
+}
+
+func (p staticIdentity) Identity(client k8sclient.Interface, namespace string) (Identity, error) {
+	return p.identity, nil
+}
+
+// WithIdentityProvider sets how the identity and owner of the lock are
+// determined, instead of looking up the current pod by hostname.
+func WithIdentityProvider(provider IdentityProvider) Option {
+	return func(e *Elector) {
+		e.identityProvider = provider
+	}
+}
+
+// static returns the identity set with StaticIdentity, if any, which is known
+// before the election is set up.
+func (e *Elector) static() (Identity, bool) {
+	p, ok := e.identityProvider.(staticIdentity)
+	return p.identity, ok
+}
+
+// staticIdentity returns true if the identity was set with StaticIdentity, so
+// there is no pod to look up.
+func (e *Elector) staticIdentity() bool {
+	_, ok := e.static()
+	return ok
+}
+
+// externalIdentity returns true if the identity has no owner in the cluster,
+// so there is no pod to look up.
+func (e *Elector) externalIdentity() bool {
+	id, ok := e.static()
+	return ok && id.Owner.Name == ""
+}
@@ -0,0 +1,117 @@
+package leader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIdentityProviders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	podNameFile := filepath.Join(dir, "name")
+	if err := ioutil.WriteFile(podNameFile, []byte("pod-a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(DefaultPodNameEnv)
+	os.Setenv(DefaultPodNameEnv, "pod-a")
+
+	tests := []struct {
+		name     string
+		provider IdentityProvider
+		wantName string
+		wantPod  bool
+		wantErr  bool
+	}{
+		{
+			name:     "hostname",
+			provider: HostnameIdentity("pod-a", ""),
+			wantName: "pod-a",
+			wantPod:  true,
+		},
+		{
+			name:     "fully qualified hostname",
+			provider: HostnameIdentity("pod-a.svc.example.com", ""),
+			wantName: "pod-a",
+			wantPod:  true,
+		},
+		{
+			name:     "hostname of no pod, with a pod name file",
+			provider: HostnameIdentity("host", podNameFile),
+			wantName: "pod-a",
+			wantPod:  true,
+		},
+		{
+			name:     "hostname of no pod",
+			provider: HostnameIdentity("host", ""),
+			wantErr:  true,
+		},
+		{
+			name:     "environment",
+			provider: EnvIdentity(""),
+			wantName: "pod-a",
+			wantPod:  true,
+		},
+		{
+			name:     "unset environment variable",
+			provider: EnvIdentity("UNSET_POD_NAME"),
+			wantErr:  true,
+		},
+		{
+			name:     "StatefulSet ordinal",
+			provider: StatefulSetIdentity("app", 1),
+			wantName: "app-1",
+			wantPod:  true,
+		},
+		{
+			name:     "static",
+			provider: StaticIdentity("vm-1", metav1.OwnerReference{}),
+			wantName: "vm-1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := newFakeCluster(newFakeClock(), testPod("pod-a"), testPod("app-1"))
+			id, err := tc.provider.Identity(cluster, testNamespace)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr {
+				return
+			}
+			if id.Name != tc.wantName {
+				t.Errorf("identity is %q, expected %q", id.Name, tc.wantName)
+			}
+			if tc.wantPod != (id.Pod != nil) {
+				t.Errorf("pod is %v; pod expected: %t", id.Pod, tc.wantPod)
+			}
+			if tc.wantPod && (id.Owner.Kind != "Pod" || id.Owner.Name != tc.wantName || id.Owner.UID != id.Pod.UID) {
+				t.Errorf("owner is %+v, expected pod %s", id.Owner, tc.wantName)
+			}
+		})
+	}
+}
+
+func TestWithIdentityProvider(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("app-1"))
+	e := newTestElector("lock", cluster, clock, WithIdentityProvider(StatefulSetIdentity("app", 1)))
+	if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	cm := cluster.lock(t, "lock")
+	if got := lockHolder(cm); got != "app-1" {
+		t.Errorf("lock is held by %q, expected app-1", got)
+	}
+	if !ownedBy(cm, podOwnerRef(testPod("app-1"))) {
+		t.Errorf("lock is owned by %s, expected app-1-uid", ownerUIDs(cm))
+	}
+}
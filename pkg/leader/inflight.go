@@ -0,0 +1,116 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrElectionInProgress indicates that another Elector in this process is
+// already campaigning for the same lock under the same identity.
+var ErrElectionInProgress = errors.New("an election for this lock is already in progress in this process")
+
+// ErrConflictingOwner indicates that a call to Become or BecomeWithOwner
+// named a different owner than a concurrent call for the same lock in this
+// process, so the two can't share its result.
+var ErrConflictingOwner = errors.New("a concurrent call for this lock uses a different owner")
+
+// campaigns tracks the Electors in this process that are currently trying to
+// become the leader, keyed by the namespace and name of their lock and the
+// identity they campaign under. Electors with different identities are
+// separate candidates, even in one process, and may race as usual.
+var campaigns = struct {
+	sync.Mutex
+	m map[string]*Elector
+}{m: map[string]*Elector{}}
+
+// claimCampaign records that e is campaigning for its lock. It returns
+// ErrElectionInProgress if some other Elector with the same identity already
+// is. The identity must already be resolved.
+func (e *Elector) claimCampaign() error {
+	key := e.ns + "/" + e.name + "/" + e.identity
+	campaigns.Lock()
+	defer campaigns.Unlock()
+	if other, ok := campaigns.m[key]; ok && other != e {
+		return ErrElectionInProgress
+	}
+	campaigns.m[key] = e
+	return nil
+}
+
+func (e *Elector) releaseCampaign() {
+	key := e.ns + "/" + e.name + "/" + e.identity
+	campaigns.Lock()
+	defer campaigns.Unlock()
+	if campaigns.m[key] == e {
+		delete(campaigns.m, key)
+	}
+}
+
+// sharedCall is a call to become the leader that other callers can wait on.
+type sharedCall struct {
+	// ctx is the context the call was made with
+	ctx context.Context
+	// owner is the owner the call was made with, or empty for the current pod
+	owner metav1.OwnerReference
+	done  chan struct{}
+	err   error
+}
+
+// sharedCalls tracks in-flight calls to the package-level Become functions,
+// keyed by the namespace and name of their lock, so concurrent calls for the
+// same lock share one loop.
+var sharedCalls = struct {
+	sync.Mutex
+	m map[string]*sharedCall
+}{m: map[string]*sharedCall{}}
+
+// becomeShared calls e.Become, unless a call for the same lock is already in
+// flight, in which case it waits for and returns that call's result. If that
+// call gave up because its own ctx was done while this caller's is not, this
+// caller tries again rather than return the other's ctx.Err(). If the call in
+// flight was made with a different owner, ErrConflictingOwner is returned
+// instead.
+func becomeShared(ctx context.Context, e *Elector, owner metav1.OwnerReference) error {
+	if err := e.setup(); err != nil {
+		return err
+	}
+	key := e.ns + "/" + e.name
+	for {
+		sharedCalls.Lock()
+		c, ok := sharedCalls.m[key]
+		if !ok {
+			c = &sharedCall{ctx: ctx, owner: owner, done: make(chan struct{})}
+			sharedCalls.m[key] = c
+			sharedCalls.Unlock()
+
+			c.err = e.Become(ctx)
+
+			sharedCalls.Lock()
+			delete(sharedCalls.m, key)
+			sharedCalls.Unlock()
+			close(c.done)
+			return c.err
+		}
+		sharedCalls.Unlock()
+
+		if !sameOwner(c.owner, owner) {
+			return ErrConflictingOwner
+		}
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if c.err == nil || c.err != c.ctx.Err() || ctx.Err() != nil {
+			return c.err
+		}
+	}
+}
+
+// sameOwner returns true if a and b refer to the same object.
+func sameOwner(a, b metav1.OwnerReference) bool {
+	return a.APIVersion == b.APIVersion && a.Kind == b.Kind && a.Name == b.Name && a.UID == b.UID
+}
@@ -0,0 +1,100 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBecomeShared(t *testing.T) {
+	clock := newFakeClock()
+	other := testPod("pod-a")
+	other.Namespace = "other"
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), other, testLock("lock", "pod-b", nil))
+	first := newTestElector("lock", cluster, clock)
+	second := newTestElector("lock", cluster, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	firstResult := async(func() error { return becomeShared(ctx, first, metav1.OwnerReference{}) })
+	never(t, clock, time.Second, firstResult)
+	secondResult := async(func() error { return becomeShared(ctx, second, metav1.OwnerReference{}) })
+	never(t, clock, time.Second, secondResult)
+
+	// a call for a different owner can't share the result
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "Pod", Name: "pod-a", UID: "pod-a-uid"}
+	if err := becomeShared(ctx, newTestElector("lock", cluster, clock), owner); err != ErrConflictingOwner {
+		t.Errorf("unexpected error for a different owner: %v", err)
+	}
+
+	// a lock of the same name in another namespace is a different lock
+	elsewhere := newTestElector("lock", cluster, clock, WithNamespace("other"))
+	if err := await(t, clock, 0, async(func() error { return becomeShared(ctx, elsewhere, metav1.OwnerReference{}) })); err != nil {
+		t.Fatalf("Become in another namespace failed: %s", err.Error())
+	}
+
+	cluster.deletePod(t, "pod-b")
+	for _, result := range []<-chan error{firstResult, secondResult} {
+		if err := await(t, clock, time.Second, result); err != nil {
+			t.Fatalf("Become failed: %s", err.Error())
+		}
+	}
+	if first.Status().State != StateLeader || second.Status().State == StateLeader {
+		t.Error("the second call did not share the first one's election")
+	}
+}
+
+func TestBecomeSharedCancelled(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), testLock("lock", "pod-b", nil))
+	first := newTestElector("lock", cluster, clock)
+	second := newTestElector("lock", cluster, clock)
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstResult := async(func() error { return becomeShared(firstCtx, first, metav1.OwnerReference{}) })
+	never(t, clock, time.Second, firstResult)
+	secondResult := async(func() error { return becomeShared(context.Background(), second, metav1.OwnerReference{}) })
+	never(t, clock, time.Second, secondResult)
+
+	// the first caller gives up, but the second keeps trying
+	cancelFirst()
+	if err := await(t, clock, 0, firstResult); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	never(t, clock, time.Second, secondResult)
+
+	cluster.deletePod(t, "pod-b")
+	if err := await(t, clock, time.Second, secondResult); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if state := second.Status().State; state != StateLeader {
+		t.Errorf("state is %s, expected leader", state)
+	}
+}
+
+func TestClaimCampaign(t *testing.T) {
+	a := NewElector("lock", WithNamespace(testNamespace))
+	a.identity = "pod-a"
+	same := NewElector("lock", WithNamespace(testNamespace))
+	same.identity = "pod-a"
+	other := NewElector("lock", WithNamespace(testNamespace))
+	other.identity = "pod-b"
+
+	if err := a.claimCampaign(); err != nil {
+		t.Fatalf("claim failed: %s", err.Error())
+	}
+	if err := same.claimCampaign(); err != ErrElectionInProgress {
+		t.Errorf("unexpected error for the same identity: %v", err)
+	}
+	if err := other.claimCampaign(); err != nil {
+		t.Errorf("claim under another identity failed: %s", err.Error())
+	}
+	other.releaseCampaign()
+	a.releaseCampaign()
+	if err := same.claimCampaign(); err != nil {
+		t.Errorf("claim after release failed: %s", err.Error())
+	}
+	same.releaseCampaign()
+}
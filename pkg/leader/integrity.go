@@ -0,0 +1,83 @@
+package leader
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxIntegrityFailures is how many locks in a row may fail verification before
+// the Elector gives up.
+const maxIntegrityFailures = 3
+
+// IntegrityError indicates that a lock read back right after it was created
+// did not match what was written, as happens when an admission webhook mutates
+// or replaces the object. Field names what differed.
+type IntegrityError struct {
+	Field string
+	Want  string
+	Got   string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("lock failed verification after creation: %s is %q, expected %q", e.Field, e.Got, e.Want)
+}
+
+// verifyCreated re-reads a lock this Elector just created and checks that its
+// UID, owner and holder are as written. If it fails verification, an
+// *IntegrityError is returned, and the lock is deleted unless it has already
+// been replaced by someone else's.
+func (e *Elector) verifyCreated(created *corev1.ConfigMap) error {
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	cm, err := cms.Get(e.name, metav1.GetOptions{})
+	if err != nil {
+		return e.permissionError(err, "get", "configmaps")
+	}
+
+	var problem *IntegrityError
+	switch {
+	case cm.UID != created.UID:
+		problem = &IntegrityError{Field: "uid", Want: string(created.UID), Got: string(cm.UID)}
+	case lockHolder(cm) != e.identity:
+		problem = &IntegrityError{Field: "holder", Want: e.identity, Got: lockHolder(cm)}
+	case cm.Annotations[instanceAnnotation] != e.instance:
+		problem = &IntegrityError{Field: "instance", Want: e.instance, Got: cm.Annotations[instanceAnnotation]}
+	case e.owner.UID != "" && !ownedBy(cm, e.owner):
+		problem = &IntegrityError{Field: "owner", Want: string(e.owner.UID), Got: ownerUIDs(cm)}
+	default:
+		return nil
+	}
+
+	if problem.Field == "uid" {
+		// the lock read back is not the one created, so it is not ours to
+		// delete
+		e.log().Error(problem.Error())
+		return problem
+	}
+	e.log().Errorf("%s; deleting it", problem.Error())
+	err = cms.Delete(e.name, metav1.NewPreconditionDeleteOptions(string(created.UID)))
+	if err != nil && !apierrors.IsNotFound(err) {
+		e.log().Warnf("failed to delete lock that failed verification: %s", err.Error())
+	}
+	return problem
+}
+
+func ownedBy(cm *corev1.ConfigMap, owner metav1.OwnerReference) bool {
+	for _, ref := range cm.GetOwnerReferences() {
+		if ref.UID == owner.UID {
+			return true
+		}
+	}
+	return false
+}
+
+func ownerUIDs(cm *corev1.ConfigMap) string {
+	var uids []string
+	for _, ref := range cm.GetOwnerReferences() {
+		uids = append(uids, string(ref.UID))
+	}
+	return strings.Join(uids, ",")
+}
@@ -0,0 +1,79 @@
+package leader
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestVerifyCreated(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	// a webhook changes the holder of every lock
+	cluster.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		cm := action.(k8stesting.CreateAction).GetObject().(*corev1.ConfigMap)
+		if cm.Name != "lock" {
+			return false, nil, nil
+		}
+		cm.Annotations[holderAnnotation] = "pod-b"
+		return cluster.createConfigMap(action)
+	})
+	e := newTestElector("lock", cluster, clock)
+
+	err := await(t, clock, maxBackoff, async(func() error { return e.Become(context.Background()) }))
+	if problem, ok := err.(*IntegrityError); !ok || problem.Field != "holder" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cm := cluster.lock(t, "lock"); cm != nil {
+		t.Errorf("lock %s that failed verification was not deleted", cm.UID)
+	}
+	if attempts := e.Status().Attempts; attempts != maxIntegrityFailures {
+		t.Errorf("made %d attempts, expected %d", attempts, maxIntegrityFailures)
+	}
+}
+
+func TestVerifyCreatedReplaced(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"))
+	// pod-b's lock replaces pod-a's between its creation and verification
+	created := make(chan struct{}, 1)
+	cluster.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		select {
+		case created <- struct{}{}:
+		default:
+		}
+		return false, nil, nil
+	})
+	cluster.PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		select {
+		case <-created:
+			if err := cluster.tracker.Delete(configMapsResource, testNamespace, "lock"); err != nil {
+				t.Error(err)
+			}
+			if err := cluster.tracker.Add(testLock("lock", "pod-b", nil)); err != nil {
+				t.Error(err)
+			}
+		default:
+		}
+		return false, nil, nil
+	})
+	e := newTestElector("lock", cluster, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return e.Become(ctx) })
+	never(t, clock, maxBackoff, result)
+	if cm := cluster.lock(t, "lock"); cm == nil || cm.UID != "lock-pod-b" {
+		t.Errorf("lock is %v, expected pod-b's lock to be left alone", cm)
+	}
+	if holder := e.Status().Holder; holder != "pod-b" {
+		t.Errorf("waiting on holder %q, expected pod-b", holder)
+	}
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package leader
+
+import (
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Prefixes of the annotations set on the leader's pod with WithPodAnnotations,
+// followed by the name of the lock.
+const (
+	podLeaderAnnotationPrefix = "leader.of/"
+	podSinceAnnotationPrefix  = "leader.since/"
+)
+
+// labelLeaderPod sets or removes the label configured with WithLeaderLabel on
+// the current pod. When setting it, the label is also removed from any other
+// pod that still has it, such as a previous leader that exited without
+// cleaning up. Errors are logged and otherwise ignored.
+func (e *Elector) labelLeaderPod(leading bool) {
+	if e.leaderLabelKey == "" || e.podName == "" {
+		return
+	}
+	if err := e.patchLeaderLabel(e.podName, leading); err != nil {
+		e.log().Warnf("failed to update leader label on pod %s: %s", e.podName, err.Error())
+	}
+	if !leading {
+		return
+	}
+
+	selector := labels.Set{e.leaderLabelKey: e.leaderLabelValue}.AsSelector().String()
+	pods, err := e.client.CoreV1().Pods(e.ns).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		e.log().Warnf("failed to list pods with the leader label: %s", err.Error())
+		return
+	}
+	for _, pod := range pods.Items {
+		if pod.Name == e.podName {
+			continue
+		}
+		e.log().Infof("Removing stale leader label from pod %s.", pod.Name)
+		if err := e.patchLeaderLabel(pod.Name, false); err != nil {
+			e.log().Warnf("failed to remove leader label from pod %s: %s", pod.Name, err.Error())
+		}
+	}
+}
+
+// patchLeaderLabel sets or removes the leader label on the named pod.
+func (e *Elector) patchLeaderLabel(podName string, set bool) error {
+	var value interface{}
+	if set {
+		value = e.leaderLabelValue
+	}
+	return e.patchPodMetadata(podName, "labels", map[string]interface{}{e.leaderLabelKey: value})
+}
+
+// annotateLeaderPod sets or removes the annotations enabled with
+// WithPodAnnotations on the current pod. Errors are logged and otherwise
+// ignored.
+func (e *Elector) annotateLeaderPod(leading bool, at time.Time) {
+	if e.podName == "" {
+		return
+	}
+	values := map[string]interface{}{
+		podLeaderAnnotationPrefix + e.name: nil,
+		podSinceAnnotationPrefix + e.name:  nil,
+	}
+	if leading {
+		values[podLeaderAnnotationPrefix+e.name] = "true"
+		values[podSinceAnnotationPrefix+e.name] = at.UTC().Format(time.RFC3339)
+	}
+	if err := e.patchPodMetadata(e.podName, "annotations", values); err != nil {
+		e.log().Warnf("failed to update leadership annotations on pod %s: %s", e.podName, err.Error())
+	}
+}
+
+// patchPodMetadata merges values into the labels or annotations of the named
+// pod. Keys with a nil value are removed.
+func (e *Elector) patchPodMetadata(podName, field string, values map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			field: values,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = e.client.CoreV1().Pods(e.ns).Patch(podName, types.MergePatchType, patch)
+	return err
+}
@@ -1,11 +1,11 @@
 package leader
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"os"
 	"strings"
-	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -20,11 +20,20 @@ import (
 // environment
 var ErrNoNS = errors.New("namespace not found for current environment")
 
+// ErrIdentityWithoutLease indicates that WithIdentity was used without
+// WithLease.
+var ErrIdentityWithoutLease = errors.New("an identity provided with WithIdentity requires WithLease")
+
 // TryBecome behaves like Become, except it will not return an error in the
-// case where a namespace cannot be found for the current pod. This is useful
-// for a service that might run outside the cluster, for example an operator
-// being started with `operator-sdk up local`.
+// case where a namespace cannot be found for the current pod, or the process is
+// not running in a cluster at all. This is useful for a service that might run
+// outside the cluster, for example an operator being started with
+// `operator-sdk up local`, which sets WATCH_NAMESPACE.
 func TryBecome(name string) error {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		logrus.Warn("leader election disabled; not running in a cluster")
+		return nil
+	}
 	err := Become(name)
 	if err == ErrNoNS {
 		logrus.Warn("leader election disabled; no namespace was detected")
@@ -39,71 +48,25 @@ func TryBecome(name string) error {
 // the same name, so the pod that successfully creates the ConfigMap is the
 // leader. Upon termination of that pod, the garbage collector will delete the
 // ConfigMap, enabling a different pod to become the leader.
+//
+// A lock already held under the current pod's name is resumed, as after a
+// container restart.
+//
+// Concurrent calls for the same lock within one process share a single
+// attempt.
 func Become(name string) error {
-	logrus.Info("trying to become the leader")
-
-	ns, err := myNS()
-	if err != nil {
-		return err
-	}
-
-	client, err := getClientset()
-	if err != nil {
-		return err
-	}
-
-	owner, err := myOwnerRef(client, ns)
-	if err != nil {
-		return err
-	}
-
-	cm := &corev1.ConfigMap{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "v1",
-			Kind:       "ConfigMap",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:            name,
-			Namespace:       ns,
-			OwnerReferences: []metav1.OwnerReference{owner},
-		},
-	}
-
-	// check for existing lock from this pod, in case we got restarted
-	existing, err := client.CoreV1().ConfigMaps(ns).Get(name, metav1.GetOptions{})
-	switch {
-	case err == nil:
-		for _, existingOwner := range existing.GetOwnerReferences() {
-			if existingOwner.Name == owner.Name {
-				logrus.Info("Found existing lock with my name. I was likely restarted.")
-				logrus.Info("Continuing as the leader.")
-				return nil
-			} else {
-				logrus.Infof("Found existing lock from %s", existingOwner.Name)
-			}
-		}
-	case apierrors.IsNotFound(err):
-		logrus.Info("No pre-existing lock was found.")
-	default:
-		logrus.Error("unknown error trying to get ConfigMap")
-		return err
-	}
+	return becomeShared(context.Background(), NewElector(name), metav1.OwnerReference{})
+}
 
-	// try to create a lock
-	for {
-		_, err := client.CoreV1().ConfigMaps(ns).Create(cm)
-		switch {
-		case err == nil:
-			logrus.Info("Became the leader.")
-			return nil
-		case apierrors.IsAlreadyExists(err):
-			logrus.Info("Not the leader. Waiting.")
-			time.Sleep(time.Second * 1)
-		default:
-			logrus.Error("unknown error creating configmap")
-			return err
-		}
-	}
+// BecomeWithOwner behaves like Become, except the lock is owned by the provided
+// object instead of the current pod. This is useful for callers that already
+// know their owning object, such as a ReplicaSet or a custom controller, and
+// for environments where permission to get pods is not granted.
+//
+// Concurrent calls for the same lock within one process share a single attempt
+// if they name the same owner; ErrConflictingOwner is returned otherwise.
+func BecomeWithOwner(ctx context.Context, name string, owner metav1.OwnerReference) error {
+	return becomeShared(ctx, NewElector(name, WithOwner(owner)), owner)
 }
 
 // getClientset returns a k8sclient.Clientset based on the current in-cluster
@@ -120,8 +83,31 @@ func getClientset() (*k8sclient.Clientset, error) {
 	return cs, nil
 }
 
+// Environment variables that operator-sdk based operators use to convey
+// namespaces.
+const (
+	operatorNamespaceEnv = "OPERATOR_NAMESPACE"
+	watchNamespaceEnv    = "WATCH_NAMESPACE"
+)
+
 // myNS returns the name of the namespace in which this code is currently running.
-func myNS() (string, error) {
+// In order of precedence, it is taken from:
+//
+// 1. the OPERATOR_NAMESPACE environment variable
+// 2. the WATCH_NAMESPACE environment variable, if it names exactly one namespace
+// 3. the pod's service account
+func myNS(log logrus.FieldLogger) (string, error) {
+	if ns := os.Getenv(operatorNamespaceEnv); ns != "" {
+		log.Infof("found namespace in %s: %s", operatorNamespaceEnv, ns)
+		return ns, nil
+	}
+	// an empty WATCH_NAMESPACE means all namespaces, and a comma-separated
+	// list names several; neither identifies where the lock belongs
+	if ns := os.Getenv(watchNamespaceEnv); ns != "" && !strings.Contains(ns, ",") {
+		log.Infof("found namespace in %s: %s", watchNamespaceEnv, ns)
+		return ns, nil
+	}
+
 	nsBytes, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -130,30 +116,60 @@ func myNS() (string, error) {
 		return "", err
 	}
 	ns := strings.TrimSpace(string(nsBytes))
-	logrus.Infof("found namespace: %s", ns)
+	log.Infof("found namespace: %s", ns)
 	return ns, nil
 }
 
-// myOwnerRef returns an OwnerReference that corresponds to the pod in which
-// this code is currently running.
-func myOwnerRef(client *k8sclient.Clientset, ns string) (metav1.OwnerReference, error) {
-	hostname, err := os.Hostname()
-	if err != nil {
-		return metav1.OwnerReference{}, err
+// podOwnerRef returns an OwnerReference that corresponds to the provided pod.
+func podOwnerRef(pod *corev1.Pod) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       pod.ObjectMeta.Name,
+		UID:        pod.ObjectMeta.UID,
 	}
-	logrus.Infof("found hostname: %s", hostname)
+}
 
-	myPod, err := client.CoreV1().Pods(ns).Get(hostname, metav1.GetOptions{})
-	if err != nil {
-		logrus.Error("failed to get pod")
-		return metav1.OwnerReference{}, err
+// myPod returns the pod in which this code is currently running. The pod is
+// looked up by hostname, which is taken from the OS unless provided, and by
+// the hostname's first label if it is fully qualified. If no pod has that name,
+// and podNameFile is not empty, the pod name is read from that file instead.
+// Such a file can be provided with the downward API.
+func myPod(log logrus.FieldLogger, client k8sclient.Interface, ns, hostname, podNameFile string) (*corev1.Pod, error) {
+	if hostname == "" {
+		var err error
+		hostname, err = os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("found hostname: %s", hostname)
 	}
 
-	owner := metav1.OwnerReference{
-		APIVersion: "v1",
-		Kind:       "Pod",
-		Name:       myPod.ObjectMeta.Name,
-		UID:        myPod.ObjectMeta.UID,
+	name := hostname
+	pod, err := client.CoreV1().Pods(ns).Get(name, metav1.GetOptions{})
+	if short := strings.SplitN(hostname, ".", 2)[0]; apierrors.IsNotFound(err) && short != hostname {
+		log.Infof("no pod named %s; trying %s", hostname, short)
+		name = short
+		pod, err = client.CoreV1().Pods(ns).Get(name, metav1.GetOptions{})
+	}
+	if apierrors.IsNotFound(err) && podNameFile != "" {
+		log.Infof("no pod named %s; reading pod name from %s", hostname, podNameFile)
+		nameBytes, readErr := ioutil.ReadFile(podNameFile)
+		if readErr != nil {
+			log.Error("failed to read pod name file")
+			return nil, readErr
+		}
+		name = strings.TrimSpace(string(nameBytes))
+		log.Infof("found pod name: %s", name)
+		pod, err = client.CoreV1().Pods(ns).Get(name, metav1.GetOptions{})
+	}
+	if apierrors.IsNotFound(err) {
+		log.Errorf("no pod named %s in namespace %s; if the hostname differs from the pod name, provide it with WithHostname or WithPodNameFile", name, ns)
+		return nil, err
+	}
+	if err != nil {
+		log.Errorf("failed to get pod %s: %s", name, err.Error())
+		return nil, err
 	}
-	return owner, nil
+	return pod, nil
 }
@@ -0,0 +1,190 @@
+package leader
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Keys of the lock's data used with WithLease.
+const (
+	leaseHolderKey   = "holder"
+	leaseRenewKey    = "renewTime"
+	leaseDurationKey = "leaseDurationSeconds"
+	// leaseAcquireKey is when the current term of the lease began, in RFC
+	// 3339 format. Renewals and resuming after a restart keep it.
+	leaseAcquireKey = "acquireTime"
+)
+
+// becomeLease blocks until this Elector holds the lease, or ctx is done.
+func (e *Elector) becomeLease(ctx context.Context) error {
+	for {
+		if err := e.delayAttempt(ctx); err != nil {
+			return err
+		}
+
+		e.addAttempt()
+		acquired, err := e.tryAcquireLease()
+		switch {
+		case err != nil:
+			e.log().Error("unknown error acquiring lease")
+			return err
+		case acquired:
+			e.log().Info("Became the leader.")
+			e.setLeader(e.identity, e.clock.Now())
+			return nil
+		}
+
+		e.log().Info("Not the leader. Waiting.")
+		e.checkStandby()
+		select {
+		case <-e.sleeper.After(e.backoff.When(e.name)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// tryAcquireLease creates the lease, or takes it over if it is free, expired
+// or already held by this Elector. Updates are made with the resourceVersion
+// that was read, so concurrent candidates can't both succeed. It returns true
+// if this Elector now holds the lease.
+//
+// Expiry is judged by how long the lease has gone unchanged as observed by this
+// Elector, not by the renewal time recorded on it, so that clock skew between
+// pods does not matter.
+func (e *Elector) tryAcquireLease() (bool, error) {
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	cm, err := cms.Get(e.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		// a lease is not owned by the pod, so it outlives each leader
+		lease := e.newLock(metav1.OwnerReference{})
+		e.setLeaseData(lease)
+		lease.Data[leaseAcquireKey] = e.clock.Now().UTC().Format(time.RFC3339)
+		e.stampEpoch(lease.Annotations)
+		created, err := cms.Create(lease)
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, e.permissionError(err, "create", "configmaps")
+		}
+		e.observeLease(created.ResourceVersion)
+		e.lockUID = created.UID
+		e.setEpoch(created)
+		e.renewedAt = e.clock.Now()
+		return true, nil
+	case err != nil:
+		return false, e.permissionError(err, "get", "configmaps")
+	}
+
+	e.observeLease(cm.ResourceVersion)
+	e.observeLock(cm)
+	e.observeEpoch(cm)
+	e.observeHandoff(cm)
+	e.checkCompatibility(cm)
+	holder := cm.Data[leaseHolderKey]
+	e.setHolder(holder)
+	if zone := cm.Annotations[zoneAnnotation]; zone != "" {
+		e.lastLeaderZone = zone
+	}
+	if holder != "" && holder != e.identity && e.since(e.leaseObservedAt) < leaseDuration(cm.Data) {
+		return false, nil
+	}
+	if holder == e.identity && e.detectConflicts && e.otherInstance(cm) && e.since(e.leaseObservedAt) < leaseDuration(cm.Data) {
+		// another process may hold it under this identity; wait for the
+		// lease to go unrenewed before resuming it
+		return false, nil
+	}
+	if holder != e.identity {
+		e.log().Infof("Taking over expired lease from %s.", holder)
+	}
+
+	cm = cm.DeepCopy()
+	e.setLeaseData(cm)
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	e.annotateHolder(cm.Annotations)
+	if holder != e.identity {
+		// a new term; resuming after a restart keeps the epoch
+		e.stampEpoch(cm.Annotations)
+		cm.Data[leaseAcquireKey] = e.clock.Now().UTC().Format(time.RFC3339)
+	}
+	updated, err := cms.Update(cm)
+	if apierrors.IsConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, e.permissionError(err, "update", "configmaps")
+	}
+	if holder != "" && holder != e.identity {
+		e.tookOver(holder)
+	}
+	e.observeLease(updated.ResourceVersion)
+	e.lockUID = updated.UID
+	e.setEpoch(updated)
+	e.renewedAt = e.clock.Now()
+	return true, nil
+}
+
+// renewLease records a new renewal time on the lease, provided this Elector
+// still holds it. It returns false if the lease is held by another or has
+// gone unrenewed for longer than its duration. Other errors are logged, and the
+// next check tries again.
+func (e *Elector) renewLease(cm *corev1.ConfigMap) bool {
+	if cm.Data[leaseHolderKey] != e.identity {
+		return false
+	}
+	cm = cm.DeepCopy()
+	e.setLeaseData(cm)
+	updated, err := e.client.CoreV1().ConfigMaps(e.ns).Update(cm)
+	if err != nil {
+		e.log().Warnf("failed to renew lease: %s", err.Error())
+		e.setError(err)
+		return !e.leaseExpired()
+	}
+	e.observeLease(updated.ResourceVersion)
+	e.renewedAt = e.clock.Now()
+	return true
+}
+
+// leaseExpired returns true if this Elector holds a lease that it has failed
+// to renew for longer than its duration, so that other candidates may have
+// taken it over.
+func (e *Elector) leaseExpired() bool {
+	return e.leaseDuration > 0 && e.since(e.renewedAt) >= e.leaseDuration
+}
+
+// setLeaseData records this Elector as the holder of the lease, as of now.
+// Other data on the lock, as published with UpdateLeaderData, is kept.
+func (e *Elector) setLeaseData(cm *corev1.ConfigMap) {
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[leaseHolderKey] = e.identity
+	cm.Data[leaseRenewKey] = e.clock.Now().UTC().Format(time.RFC3339)
+	cm.Data[leaseDurationKey] = strconv.Itoa(int(e.leaseDuration / time.Second))
+}
+
+// observeLease records when the lease was seen to change.
+func (e *Elector) observeLease(resourceVersion string) {
+	if resourceVersion != e.leaseVersion {
+		e.leaseVersion = resourceVersion
+		e.leaseObservedAt = e.clock.Now()
+	}
+}
+
+// leaseDuration returns the duration recorded in the provided lease data.
+func leaseDuration(data map[string]string) time.Duration {
+	seconds, err := strconv.Atoi(data[leaseDurationKey])
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,228 @@
+package leader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testLeaseDuration = time.Second * 15
+
+// testAcquireTime is when the term of a lease made by testLease began.
+const testAcquireTime = "2019-12-31T00:00:00Z"
+
+// testLease returns a lease called name held by holder, as written by an
+// earlier process.
+func testLease(name, holder string, epoch string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       testNamespace,
+			UID:             "lease-uid",
+			ResourceVersion: "1",
+			Labels:          map[string]string{LockLabel: "true"},
+			Annotations:     map[string]string{epochAnnotation: epoch},
+		},
+		Data: map[string]string{
+			leaseHolderKey:   holder,
+			leaseRenewKey:    "2020-01-01T00:00:00Z",
+			leaseDurationKey: "15",
+			leaseAcquireKey:  testAcquireTime,
+		},
+	}
+}
+
+func TestLeaseAcquire(t *testing.T) {
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		// renewals is how many times the holder renews the lease, every
+		// third of its duration, before it stops
+		renewals     int
+		wantEpoch    int64
+		wantTerm     bool
+		wantTakeover bool
+	}{
+		{
+			name:      "no lease",
+			wantEpoch: 1,
+			wantTerm:  true,
+		},
+		{
+			name:      "own lease is resumed",
+			objs:      []runtime.Object{testLease("lease", "pod-a", "5")},
+			wantEpoch: 5,
+		},
+		{
+			name:         "unrenewed lease is taken over",
+			objs:         []runtime.Object{testLease("lease", "pod-b", "5")},
+			wantEpoch:    6,
+			wantTerm:     true,
+			wantTakeover: true,
+		},
+		{
+			name:         "renewed lease is taken over once renewals stop",
+			objs:         []runtime.Object{testLease("lease", "pod-b", "5")},
+			renewals:     10,
+			wantEpoch:    6,
+			wantTerm:     true,
+			wantTakeover: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, append(tc.objs, testPod("pod-a"))...)
+			e := newTestElector("lease", cluster, clock, WithLease(testLeaseDuration))
+
+			result := async(func() error { return e.Become(context.Background()) })
+			renewedAt := clock.Now()
+			for i := 0; i < tc.renewals; i++ {
+				cluster.modify(t, "lease", func(cm *corev1.ConfigMap) {
+					cm.Data[leaseRenewKey] = clock.Now().UTC().Format(time.RFC3339)
+				})
+				renewedAt = clock.Now()
+				never(t, clock, testLeaseDuration/30, result)
+			}
+			if err := await(t, clock, time.Second, result); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+			if unrenewed := clock.Now().Sub(renewedAt); tc.wantTakeover && unrenewed < testLeaseDuration {
+				t.Errorf("lease was taken over %s after it was renewed, expected at least %s", unrenewed, testLeaseDuration)
+			}
+
+			status := e.Status()
+			if status.State != StateLeader || status.Epoch != tc.wantEpoch {
+				t.Errorf("state is %s with epoch %d, expected leader with epoch %d", status.State, status.Epoch, tc.wantEpoch)
+			}
+			cm := cluster.lock(t, "lease")
+			if got := cm.Data[leaseHolderKey]; got != "pod-a" {
+				t.Errorf("lease is held by %q, expected pod-a", got)
+			}
+			if len(cm.OwnerReferences) != 0 {
+				t.Errorf("lease has owners %s, expected none", ownerUIDs(cm))
+			}
+			if got := lockEpoch(cm); got != tc.wantEpoch {
+				t.Errorf("lease records epoch %d, expected %d", got, tc.wantEpoch)
+			}
+			acquired := cm.Data[leaseAcquireKey]
+			if acquired == "" || tc.wantTerm != (acquired != testAcquireTime) {
+				t.Errorf("lease records acquire time %q; new term expected: %t", acquired, tc.wantTerm)
+			}
+		})
+	}
+}
+
+func TestLeaseCompareAndSwap(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testLease("lease", "pod-b", "5"))
+	// pod-c takes over the lease between pod-a reading it and writing it
+	raced := make(chan struct{})
+	var race sync.Once
+	cluster.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		race.Do(func() {
+			cluster.modify(t, "lease", func(cm *corev1.ConfigMap) {
+				cm.Data[leaseHolderKey] = "pod-c"
+			})
+			close(raced)
+		})
+		return false, nil, nil
+	})
+	e := newTestElector("lease", cluster, clock, WithLease(testLeaseDuration))
+
+	result := async(func() error { return e.Become(context.Background()) })
+	eventually(t, clock, time.Second, func() bool {
+		select {
+		case <-raced:
+			return true
+		default:
+			return false
+		}
+	})
+	racedAt := clock.Now()
+	never(t, clock, testLeaseDuration/30, result)
+	if got := cluster.lock(t, "lease").Data[leaseHolderKey]; got != "pod-c" {
+		t.Errorf("lease is held by %q, expected pod-c", got)
+	}
+
+	// pod-c does not renew it either, so it is taken over once it has gone
+	// unchanged for its duration
+	if err := await(t, clock, time.Second, result); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if unrenewed := clock.Now().Sub(racedAt); unrenewed < testLeaseDuration {
+		t.Errorf("lease was taken over %s after pod-c acquired it, expected at least %s", unrenewed, testLeaseDuration)
+	}
+	if got := cluster.lock(t, "lease").Data[leaseHolderKey]; got != "pod-a" {
+		t.Errorf("lease is held by %q, expected pod-a", got)
+	}
+}
+
+func TestLeaseMaintain(t *testing.T) {
+	tests := []struct {
+		name string
+		// change, if set, is called once the lease has been renewed, and
+		// makes it lost
+		change func(t *testing.T, cluster *fakeCluster)
+	}{
+		{
+			name: "lease is renewed",
+		},
+		{
+			name: "lease taken by another",
+			change: func(t *testing.T, cluster *fakeCluster) {
+				cluster.modify(t, "lease", func(cm *corev1.ConfigMap) {
+					cm.Data[leaseHolderKey] = "pod-b"
+				})
+			},
+		},
+		{
+			name: "API unreachable for the lease duration",
+			change: func(t *testing.T, cluster *fakeCluster) {
+				cluster.setUnreachable(true)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"))
+			e := newTestElector("lease", cluster, clock, WithLease(testLeaseDuration))
+			if err := e.campaign(context.Background()); err != nil {
+				t.Fatalf("campaign failed: %s", err.Error())
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			result := async(func() error { return e.maintain(ctx) })
+			renewed := cluster.lock(t, "lease").Data[leaseRenewKey]
+			eventually(t, clock, e.checkInterval(), func() bool {
+				return cluster.lock(t, "lease").Data[leaseRenewKey] != renewed
+			})
+			if tc.change == nil {
+				never(t, clock, e.checkInterval(), result)
+				return
+			}
+
+			tc.change(t, cluster)
+			start := clock.Now()
+			if err := await(t, clock, e.checkInterval(), result); err != ErrLeadershipLost {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if elapsed := clock.Now().Sub(start); elapsed > testLeaseDuration+e.checkInterval() {
+				t.Errorf("loss was noticed after %s, expected within %s", elapsed, testLeaseDuration)
+			}
+			if state := e.Status().State; state != StateCandidate {
+				t.Errorf("state is %s, expected candidate", state)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package leader
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// LeaderInfo describes a lock and the leader holding it.
+type LeaderInfo struct {
+	// Lock is the name of the lock.
+	Lock string `json:"lock"`
+	// Namespace is the namespace of the lock.
+	Namespace string `json:"namespace"`
+	// Holder is the identity of the leader, usually the name of its pod.
+	Holder string `json:"holder"`
+	// HolderKind is the kind of the lock's owner, usually "Pod" but possibly
+	// "Job" or another kind provided with WithOwner.
+	HolderKind string `json:"holderKind"`
+	// Address is the IP address of the leader's pod, if known.
+	Address string `json:"address,omitempty"`
+	// AcquiredAt is when the holder acquired the lock: when the lock was
+	// created, or for a lease, which outlives each leader, when the current
+	// term began.
+	AcquiredAt time.Time `json:"acquiredAt"`
+	// Age is how long the holder has held the lock.
+	Age time.Duration `json:"age"`
+	// Port is the port on which the leader accepts connections from its
+	// peers, as set with WithPeerPort, or zero.
+	Port int `json:"port,omitempty"`
+	// Version is the version of the library that created the lock, if
+	// recorded.
+	Version string `json:"version,omitempty"`
+	// Epoch increases each time leadership is acquired anew; it is kept when
+	// a leader resumes after a restart. The count is kept in the lease, or
+	// else in a "<lock>.epoch" ConfigMap that outlives each lock, so it can
+	// serve as a fencing token. That ConfigMap is labelled
+	// leader.mhrivnak.github.io/epoch-counter=true and is left behind once
+	// the election is no longer used; deleting it while candidates run may
+	// let epochs repeat. It is zero for locks created before epochs were
+	// recorded.
+	Epoch int64 `json:"epoch,omitempty"`
+	// Data is the data published by the leader with UpdateLeaderData.
+	Data map[string]string `json:"data,omitempty"`
+	// Standbys are the candidates waiting for the lock that were seen
+	// recently, most recently seen first.
+	Standbys []Candidate `json:"standbys,omitempty"`
+}
+
+// ListLeaders returns the locks created by this library in the provided
+// namespace, along with their holders. It is meant for building a view of which
+// process leads what. The client in use does not take a context, so ctx is
+// checked before each request rather than interrupting one.
+func ListLeaders(ctx context.Context, namespace string) ([]LeaderInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	client, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return NewAdmin(client).List(namespace)
+}
+
+// leaderInfo returns the LeaderInfo describing the provided lock as of now.
+func leaderInfo(cm *corev1.ConfigMap, now time.Time) LeaderInfo {
+	acquiredAt := cm.CreationTimestamp.Time
+	if at, err := time.Parse(time.RFC3339, cm.Data[leaseAcquireKey]); err == nil {
+		// a lease outlives each leader, so it records when the term began
+		acquiredAt = at
+	}
+	info := LeaderInfo{
+		Lock:       cm.Name,
+		Namespace:  cm.Namespace,
+		AcquiredAt: acquiredAt,
+		Age:        now.Sub(acquiredAt),
+	}
+	if owners := cm.GetOwnerReferences(); len(owners) > 0 {
+		info.HolderKind = owners[0].Kind
+	}
+	info.Holder = lockHolder(cm)
+	info.Address = cm.Annotations[addressAnnotation]
+	info.Version = cm.Annotations[versionAnnotation]
+	info.Port, _ = strconv.Atoi(cm.Annotations[portAnnotation])
+	info.Epoch = lockEpoch(cm)
+	info.Standbys = liveCandidates(cm, now)
+	if data := leaderData(cm); len(data) > 0 {
+		info.Data = data
+	}
+	return info
+}
@@ -0,0 +1,148 @@
+package leader
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// LockLabel is set to "true" on every lock created by this library, so that
+// locks can be found with a label selector.
+const LockLabel = "leader.mhrivnak.github.io/lock"
+
+// holderAnnotation records the identity of the leader, which differs from the
+// name of the lock's owner when the lock is owned by a Job.
+const holderAnnotation = "leader.mhrivnak.github.io/holder"
+
+// holderUIDAnnotation records the UID of the leader's pod, so that a later pod
+// with the same name is not taken for it, even when the lock is owned by a Job.
+const holderUIDAnnotation = "leader.mhrivnak.github.io/holder-uid"
+
+// addressAnnotation records the IP address of the leader's pod, so that
+// requests can be forwarded to the leader.
+const addressAnnotation = "leader.mhrivnak.github.io/address"
+
+// portAnnotation records the port, set with WithPeerPort, on which the leader
+// accepts connections from its peers.
+const portAnnotation = "leader.mhrivnak.github.io/port"
+
+// lockHolder returns the identity of the leader holding the provided lock. Locks
+// created before the holder was recorded are identified by their owner.
+func lockHolder(cm *corev1.ConfigMap) string {
+	if holder := cm.Data[leaseHolderKey]; holder != "" {
+		return holder
+	}
+	if holder := cm.Annotations[holderAnnotation]; holder != "" {
+		return holder
+	}
+	for _, owner := range cm.GetOwnerReferences() {
+		return owner.Name
+	}
+	return ""
+}
+
+// updateLock applies change to the provided lock and updates it. Several pods
+// write to a lock, as with candidates recording themselves on it, so on a
+// conflict the lock is read again and change is reapplied, provided the lock
+// has not been replaced; ErrLeadershipLost is returned if it has. change is
+// passed a copy it may modify in place.
+func (e *Elector) updateLock(cm *corev1.ConfigMap, change func(cm *corev1.ConfigMap) error) (*corev1.ConfigMap, error) {
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	uid := cm.UID
+	var updated *corev1.ConfigMap
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if cm == nil {
+			latest, err := cms.Get(e.name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if latest.UID != uid {
+				return ErrLeadershipLost
+			}
+			cm = latest
+		}
+		attempt := cm.DeepCopy()
+		cm = nil
+		if attempt.Annotations == nil {
+			attempt.Annotations = map[string]string{}
+		}
+		if err := change(attempt); err != nil {
+			return err
+		}
+		var err error
+		updated, err = cms.Update(attempt)
+		return err
+	})
+	return updated, err
+}
+
+// newLock returns the lock this Elector tries to create, owned by owner, if it
+// is set. The lock is passed to the function set with WithMutateLock, and then
+// the fields the election depends on are set again.
+func (e *Elector) newLock(owner metav1.OwnerReference) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+	}
+	if e.mutateLock != nil {
+		e.mutateLock(cm)
+		if cm.ObjectMeta.Labels == nil {
+			cm.ObjectMeta.Labels = map[string]string{}
+		}
+		if cm.ObjectMeta.Annotations == nil {
+			cm.ObjectMeta.Annotations = map[string]string{}
+		}
+	}
+
+	cm.ObjectMeta.Name = e.name
+	cm.ObjectMeta.Namespace = e.ns
+	cm.ObjectMeta.Labels[LockLabel] = "true"
+	if owner.Name != "" && !ownedBy(cm, owner) {
+		cm.ObjectMeta.OwnerReferences = append(cm.ObjectMeta.OwnerReferences, owner)
+	}
+	e.annotateHolder(cm.ObjectMeta.Annotations)
+	e.annotateParent(cm.ObjectMeta.Annotations)
+	if e.legacyName != "" && e.legacyIsRetired() {
+		cm.ObjectMeta.Annotations[legacyRetiredAnnotation] = e.legacyName
+	}
+	if e.lockTTL > 0 {
+		e.stampExpiry(cm.ObjectMeta.Annotations)
+	}
+	if e.finalizer && !hasFinalizer(cm) {
+		cm.ObjectMeta.Finalizers = append(cm.ObjectMeta.Finalizers, FinalizerName)
+	}
+	return cm
+}
+
+// annotateHolder records this Elector as the holder in the provided lock
+// annotations, along with what others may want to know about it. Details that
+// are not known are removed, so none are left over from a previous holder.
+func (e *Elector) annotateHolder(annotations map[string]string) {
+	annotations[holderAnnotation] = e.identity
+	annotations[instanceAnnotation] = e.instance
+	e.stampVersion(annotations)
+	setOrDelete(annotations, holderUIDAnnotation, string(e.podUID))
+	setOrDelete(annotations, addressAnnotation, e.podIP)
+	setOrDelete(annotations, zoneAnnotation, e.zone)
+	if e.peerPort > 0 {
+		annotations[portAnnotation] = strconv.Itoa(e.peerPort)
+	} else {
+		delete(annotations, portAnnotation)
+	}
+}
+
+func setOrDelete(annotations map[string]string, key, value string) {
+	if value == "" {
+		delete(annotations, key)
+	} else {
+		annotations[key] = value
+	}
+}
@@ -0,0 +1,199 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ErrCacheSync indicates that a Manager's caches could not be filled before
+// its context was done.
+var ErrCacheSync = errors.New("failed to sync caches")
+
+// Manager runs many elections in one process, such as one per custom
+// resource, sharing a single client and a single watch each on locks and pods
+// among them. Its Electors read their locks from the shared cache when
+// checking on them, and learn of the deletion of the leader's pod from the
+// shared pod watch, so the cost of an additional election is small.
+type Manager struct {
+	client k8sclient.Interface
+	ns     string
+	opts   []Option
+
+	// polling is set when the service account may not list and watch locks
+	// and pods, in which case there are no shared watches
+	polling bool
+	locks   cache.SharedIndexInformer
+	pods    cache.SharedIndexInformer
+
+	mu         sync.Mutex
+	electors   map[string]*Elector
+	podWaiters map[types.UID][]*ownerWatch
+}
+
+// NewManager starts the shared watches and returns a Manager once they have
+// synced. The options apply to every Elector created by the Manager, and
+// WithNamespace and WithClient, if provided, to the Manager itself. The
+// watches stop when ctx is done. If the service account may not list and
+// watch ConfigMaps and pods, no watches are started, and the Manager's
+// Electors poll instead; see WithPollInterval.
+func NewManager(ctx context.Context, opts ...Option) (*Manager, error) {
+	// an Elector with no name resolves the namespace and client from opts
+	// just as the Manager's Electors would
+	e := NewElector("", opts...)
+	if e.ns == "" {
+		ns, err := myNS(e.log())
+		if err != nil {
+			return nil, err
+		}
+		e.ns = ns
+	}
+	if e.client == nil {
+		client, err := getClientset()
+		if err != nil {
+			return nil, err
+		}
+		e.client = client
+	}
+
+	m := &Manager{
+		client:     e.client,
+		ns:         e.ns,
+		opts:       opts,
+		electors:   map[string]*Elector{},
+		podWaiters: map[types.UID][]*ownerWatch{},
+	}
+	if !m.canWatch() {
+		m.polling = true
+		e.log().Warnf("not allowed to list and watch ConfigMaps and pods; Electors will poll every %s", e.pollInterval)
+		return m, nil
+	}
+
+	m.locks = coreinformers.NewFilteredConfigMapInformer(m.client, m.ns, 0, cache.Indexers{}, func(options *metav1.ListOptions) {
+		options.LabelSelector = LockLabel + "=true"
+	})
+	m.pods = coreinformers.NewPodInformer(m.client, m.ns, 0, cache.Indexers{})
+	m.pods.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: m.podDeleted,
+	})
+
+	go m.locks.Run(ctx.Done())
+	go m.pods.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), m.locks.HasSynced, m.pods.HasSynced) {
+		return nil, ErrCacheSync
+	}
+	return m, nil
+}
+
+// canWatch returns true unless a SelfSubjectAccessReview shows that the
+// Manager may not list or watch ConfigMaps or pods.
+func (m *Manager) canWatch() bool {
+	for _, resource := range []string{"configmaps", "pods"} {
+		for _, verb := range []string{"list", "watch"} {
+			allowed, err := canI(m.client, m.ns, Permission{Resource: resource, Verb: verb})
+			if err == nil && !allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Elector returns the Elector for the named lock, creating it on first use.
+// Options provided here apply in addition to those of the Manager, and only
+// when the Elector is created.
+func (m *Manager) Elector(name string, opts ...Option) *Elector {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.electors[name]; ok {
+		return e
+	}
+	all := append([]Option{}, m.opts...)
+	all = append(all, WithNamespace(m.ns), WithClient(m.client))
+	all = append(all, opts...)
+	e := NewElector(name, all...)
+	e.shared = m
+	e.pollPods = m.polling
+	m.electors[name] = e
+	return e
+}
+
+// lock returns the named lock from the shared cache.
+func (m *Manager) lock(name string) (*corev1.ConfigMap, error) {
+	obj, exists, err := m.locks.GetStore().GetByKey(m.ns + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	return obj.(*corev1.ConfigMap), nil
+}
+
+// watchPod returns an ownerWatch whose deleted channel is closed when the pod
+// with the provided UID is deleted, as seen by the shared pod watch.
+func (m *Manager) watchPod(uid types.UID) *ownerWatch {
+	w := &ownerWatch{
+		uid:     uid,
+		deleted: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	w.release = func() { m.unwatchPod(w) }
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.podWaiters[uid] = append(m.podWaiters[uid], w)
+	return w
+}
+
+func (m *Manager) unwatchPod(w *ownerWatch) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	waiters := m.podWaiters[w.uid]
+	for i, waiter := range waiters {
+		if waiter == w {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(m.podWaiters, w.uid)
+	} else {
+		m.podWaiters[w.uid] = waiters
+	}
+}
+
+// podDeleted notifies the Electors waiting on the deleted pod.
+func (m *Manager) podDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.podWaiters[pod.UID] {
+		close(w.deleted)
+	}
+	delete(m.podWaiters, pod.UID)
+}
+
+// getLock returns this Elector's lock, from the shared cache of its Manager if
+// it has one.
+func (e *Elector) getLock() (*corev1.ConfigMap, error) {
+	if e.shared != nil && !e.shared.polling {
+		return e.shared.lock(e.name)
+	}
+	return e.client.CoreV1().ConfigMaps(e.ns).Get(e.name, metav1.GetOptions{})
+}
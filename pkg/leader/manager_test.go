@@ -0,0 +1,79 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	tests := []struct {
+		name string
+		// watch, if set, allows the Manager to list and watch ConfigMaps and
+		// pods
+		watch       bool
+		wantPolling bool
+	}{
+		{
+			name:  "shared watches",
+			watch: true,
+		},
+		{
+			name:        "polling",
+			wantPolling: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), testLock("b", "pod-b", nil))
+			if tc.watch {
+				var perms []Permission
+				for _, resource := range []string{"configmaps", "pods"} {
+					for _, verb := range []string{"list", "watch"} {
+						perms = append(perms, Permission{Resource: resource, Verb: verb})
+					}
+				}
+				allow(cluster, perms...)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			m, err := NewManager(ctx, testOptions(cluster, clock)...)
+			if err != nil {
+				t.Fatalf("NewManager failed: %s", err.Error())
+			}
+			if m.polling != tc.wantPolling {
+				t.Errorf("polling is %t, expected %t", m.polling, tc.wantPolling)
+			}
+
+			a := m.Elector("a")
+			if m.Elector("a") != a {
+				t.Error("a second Elector was created for lock a")
+			}
+			if err := await(t, clock, 0, async(func() error { return a.Become(ctx) })); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+			if got := lockHolder(cluster.lock(t, "a")); got != "pod-a" {
+				t.Errorf("lock a is held by %q, expected pod-a", got)
+			}
+
+			// the Elector for b waits on pod-b, and acquires the lock once
+			// the pod is deleted
+			b := m.Elector("b")
+			if b == a || b.pollPods != tc.wantPolling {
+				t.Errorf("Elector for lock b is %p polling %t, expected a new Elector polling %t", b, b.pollPods, tc.wantPolling)
+			}
+			result := async(func() error { return b.Become(ctx) })
+			never(t, clock, time.Second, result)
+			cluster.deletePod(t, "pod-b")
+			if err := await(t, clock, time.Second, result); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+			if got := lockHolder(cluster.lock(t, "b")); got != "pod-a" {
+				t.Errorf("lock b is held by %q, expected pod-a", got)
+			}
+		})
+	}
+}
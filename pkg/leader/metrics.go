@@ -0,0 +1,190 @@
+package leader
+
+import (
+	"net/http"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/sirupsen/logrus"
+)
+
+const metricsNamespace = "leader_election"
+
+var (
+	isLeaderGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "is_leader",
+		Help:      "1 if this process holds the lock, 0 otherwise.",
+	}, []string{"lock"})
+
+	attemptsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "attempts_total",
+		Help:      "Number of attempts to create the lock.",
+	}, []string{"lock"})
+
+	acquisitionsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "acquisitions_total",
+		Help:      "Number of times leadership was acquired.",
+	}, []string{"lock"})
+
+	lossesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "losses_total",
+		Help:      "Number of times leadership ended.",
+	}, []string{"lock"})
+
+	errorsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "errors_total",
+		Help:      "Number of errors encountered during the election.",
+	}, []string{"lock"})
+
+	identityConflictsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "identity_conflicts_total",
+		Help:      "Number of times another process was found using this process's identity.",
+	}, []string{"lock"})
+
+	standbysGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "standbys",
+		Help:      "Number of live candidates recorded on the lock, as seen by the leader.",
+	}, []string{"lock"})
+
+	observedLeaderGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "observed_leader",
+		Help:      "1 for the holder of the lock as last observed by this process.",
+	}, []string{"lock", "holder"})
+
+	waitingSecondsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "waiting_seconds",
+		Help:      "How long this process has been waiting to become the leader, or 0 while leading.",
+	}, []string{"lock"})
+
+	epochGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "epoch",
+		Help:      "Leadership epoch of the lock as last observed by this process.",
+	}, []string{"lock"})
+
+	lockAgeGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "lock_age_seconds",
+		Help:      "Age of the lock as last observed by this process.",
+	}, []string{"lock"})
+)
+
+// Collectors returns the collectors for all election metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		isLeaderGauge,
+		attemptsCounter,
+		acquisitionsCounter,
+		lossesCounter,
+		errorsCounter,
+		identityConflictsCounter,
+		standbysGauge,
+		observedLeaderGauge,
+		waitingSecondsGauge,
+		lockAgeGauge,
+		epochGauge,
+	}
+}
+
+// RegisterMetrics registers the election metrics with r, for processes that
+// already expose a Prometheus endpoint. It may be used together with
+// WithMetricsServer.
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range Collectors() {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metricsServers tracks the servers started by WithMetricsServer, so that
+// several Electors given the same address share one server.
+var (
+	metricsServersMu sync.Mutex
+	metricsServers   = map[string]bool{}
+)
+
+// serveMetrics starts an HTTP server on addr that serves the election metrics
+// on /metrics, unless one was already started on addr. The server uses its own
+// registry, so it does not conflict with metrics registered elsewhere.
+func serveMetrics(addr string) {
+	metricsServersMu.Lock()
+	defer metricsServersMu.Unlock()
+	if metricsServers[addr] {
+		return
+	}
+	metricsServers[addr] = true
+
+	registry := prometheus.NewRegistry()
+	// the collectors are new to this registry, so this cannot fail
+	_ = RegisterMetrics(registry)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	go func() {
+		logrus.Infof("serving metrics on %s", addr)
+		err := http.ListenAndServe(addr, mux)
+		logrus.Errorf("metrics server stopped: %s", err.Error())
+		metricsServersMu.Lock()
+		delete(metricsServers, addr)
+		metricsServersMu.Unlock()
+	}()
+}
+
+// pushMetricsLocked pushes the election metrics to the Pushgateway set with
+// WithPushgateway, if any. The push happens in the background, after the
+// state change that prompted it. The caller must hold e.mu.
+func (e *Elector) pushMetricsLocked() {
+	if e.pushURL == "" {
+		return
+	}
+	pusher := push.New(e.pushURL, e.pushJob).
+		Grouping("lock", e.name).
+		Grouping("instance", e.identity)
+	for _, c := range Collectors() {
+		pusher = pusher.Collector(c)
+	}
+	e.hookRunner.run(e.name, func() {
+		if err := pusher.Push(); err != nil {
+			e.log().Warnf("failed to push metrics: %s", err.Error())
+		}
+	})
+}
+
+// observeHolderLocked updates the observed leader metric when the holder
+// changes. The caller must hold e.mu.
+func (e *Elector) observeHolderLocked(holder string) {
+	if holder == e.holder {
+		return
+	}
+	if e.holder != "" {
+		observedLeaderGauge.DeleteLabelValues(e.name, e.holder)
+	}
+	if holder != "" {
+		observedLeaderGauge.WithLabelValues(e.name, holder).Set(1)
+	}
+}
+
+// observeLock updates the metrics that describe a lock seen by this Elector.
+func (e *Elector) observeLock(cm *corev1.ConfigMap) {
+	lockAgeGauge.WithLabelValues(e.name).Set(e.since(cm.CreationTimestamp.Time).Seconds())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == StateCandidate && !e.waitingSince.IsZero() {
+		waitingSecondsGauge.WithLabelValues(e.name).Set(e.since(e.waitingSince).Seconds())
+	}
+}
@@ -0,0 +1,134 @@
+package leader
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// legacyRetiredAnnotation records, on a lock set up with WithLegacyLockName,
+// the name of the legacy lock once no candidate depends on it any more, so
+// that later candidates do not acquire it again.
+const legacyRetiredAnnotation = "leader.mhrivnak.github.io/legacy-retired"
+
+// acquireLegacy blocks until this Elector also holds the legacy lock set with
+// WithLegacyLockName, or ctx is done. Holding the legacy lock keeps replicas
+// that still use the old name from becoming the leader, and waiting for it
+// keeps this Elector from becoming the leader while one of them is. Once the
+// legacy lock has been retired, it is skipped.
+func (e *Elector) acquireLegacy(ctx context.Context) error {
+	if e.legacyIsRetired() {
+		return nil
+	}
+	cm, err := e.getLock()
+	switch {
+	case err == nil && cm.Annotations[legacyRetiredAnnotation] == e.legacyName:
+		e.log().Infof("Legacy lock %s was retired; not acquiring it.", e.legacyName)
+		e.mu.Lock()
+		e.legacyRetired = true
+		e.mu.Unlock()
+		return nil
+	case err != nil && !apierrors.IsNotFound(err):
+		e.log().Warnf("failed to check whether legacy lock %s was retired: %s", e.legacyName, err.Error())
+	}
+
+	if e.legacy == nil {
+		e.legacy = NewElector(e.legacyName,
+			WithNamespace(e.ns),
+			WithClient(e.client),
+			WithClock(e.clock),
+			WithSleeper(e.sleeper),
+			WithoutPreflight(),
+		)
+		e.legacy.identity = e.identity
+		e.legacy.owner = e.owner
+		e.legacy.podName = e.podName
+		e.legacy.podUID = e.podUID
+		e.legacy.podIP = e.podIP
+		e.legacy.shared = e.shared
+		e.legacy.skipRegistry = e.skipRegistry
+		e.legacy.migrating = e
+	}
+	if e.legacy.Status().State == StateLeader {
+		return nil
+	}
+	e.log().Infof("Acquiring legacy lock %s before %s.", e.legacyName, e.name)
+	return e.legacy.become(ctx)
+}
+
+// legacyIsRetired returns true if the legacy lock is no longer needed.
+func (e *Elector) legacyIsRetired() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.legacyRetired
+}
+
+// registerMigrating records the Elector that set up this legacy Elector as a
+// candidate on its own lock too, if another pod holds it. The leader takes a
+// candidate on the legacy lock that is not also a candidate on its own lock
+// for a replica that only uses the legacy name.
+func (e *Elector) registerMigrating() {
+	m := e.migrating
+	cm, err := m.getLock()
+	if err != nil || lockHolder(cm) == m.identity {
+		return
+	}
+	m.registerCandidate(cm)
+}
+
+// retireLegacy deletes the legacy lock held alongside cm, the lock this
+// Elector leads, once every live candidate on the legacy lock is also a
+// candidate on cm, and so will not acquire the legacy lock again. The legacy
+// lock is first held for candidateTTL, so that candidates waiting for it have
+// recorded themselves. The lock is marked first, so that later candidates
+// skip the legacy lock. Errors are logged, and the next check tries again.
+func (e *Elector) retireLegacy(cm *corev1.ConfigMap) {
+	if e.legacy == nil {
+		return
+	}
+	status := e.legacy.Status()
+	if status.State != StateLeader || status.LeaderFor < candidateTTL {
+		return
+	}
+	legacy, err := e.legacy.getLock()
+	if err != nil {
+		e.log().Debugf("failed to check legacy lock %s: %s", e.legacyName, err.Error())
+		return
+	}
+	now := e.clock.Now()
+	migrating := map[string]bool{}
+	for _, c := range liveCandidates(cm, now) {
+		migrating[c.Identity] = true
+	}
+	for _, c := range liveCandidates(legacy, now) {
+		if !migrating[c.Identity] {
+			return
+		}
+	}
+
+	_, err = e.updateLock(cm, func(cm *corev1.ConfigMap) error {
+		cm.Annotations[legacyRetiredAnnotation] = e.legacyName
+		return nil
+	})
+	if err != nil {
+		e.log().Debugf("failed to mark legacy lock %s as retired: %s", e.legacyName, err.Error())
+		return
+	}
+	e.mu.Lock()
+	e.legacyRetired = true
+	e.mu.Unlock()
+	e.log().Infof("No candidate uses only legacy lock %s any more; deleting it.", e.legacyName)
+	e.releaseLegacy(context.Background())
+}
+
+// releaseLegacy releases the legacy lock, if held, so that the next leader can
+// acquire it. Errors are logged and otherwise ignored.
+func (e *Elector) releaseLegacy(ctx context.Context) {
+	if e.legacy == nil || e.legacy.Status().State != StateLeader {
+		return
+	}
+	if err := e.legacy.release(ctx); err != nil {
+		e.log().Warnf("failed to release legacy lock %s: %s", e.legacyName, err.Error())
+	}
+}
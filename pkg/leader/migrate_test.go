@@ -0,0 +1,106 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// seeCandidate records identity on the named lock as a candidate seen now, as
+// a replica waiting for it would.
+func seeCandidate(t *testing.T, cluster *fakeCluster, clock *fakeClock, name, identity string) {
+	t.Helper()
+	cluster.modify(t, name, func(cm *corev1.ConfigMap) {
+		seen, err := withCandidate(cm, identity, clock.Now(), maxCandidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cm.Annotations[candidatesAnnotation] = seen.Annotations[candidatesAnnotation]
+	})
+}
+
+func TestRetireLegacy(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	e := newTestElector("lock", cluster, clock, WithLegacyLockName("old"), WithLockTTL(testLockTTL))
+
+	if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if cm := cluster.lock(t, "old"); cm == nil || lockHolder(cm) != "pod-a" {
+		t.Fatal("legacy lock was not acquired")
+	}
+
+	// pod-b waits for both locks, and pod-c, which only uses the legacy
+	// name, keeps the legacy lock in use
+	for i := 0; i < 5; i++ {
+		seeCandidate(t, cluster, clock, "old", "pod-b")
+		seeCandidate(t, cluster, clock, "lock", "pod-b")
+		seeCandidate(t, cluster, clock, "old", "pod-c")
+		never(t, clock, candidateRefreshInterval/5, nil)
+		if cluster.lock(t, "old") == nil {
+			t.Fatal("legacy lock was deleted while pod-c uses it")
+		}
+	}
+
+	// pod-c goes away
+	eventually(t, clock, candidateRefreshInterval/5, func() bool {
+		seeCandidate(t, cluster, clock, "lock", "pod-b")
+		return cluster.lock(t, "old") == nil
+	})
+	if got := cluster.lock(t, "lock").Annotations[legacyRetiredAnnotation]; got != "old" {
+		t.Errorf("lock marks %q as retired, expected old", got)
+	}
+	if state := e.Status().State; state != StateLeader {
+		t.Errorf("state is %s, expected leader", state)
+	}
+}
+
+func TestRetiredLegacySkipped(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"),
+		testLock("lock", "pod-b", map[string]string{legacyRetiredAnnotation: "old"}))
+	e := newTestElector("lock", cluster, clock, WithLegacyLockName("old"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return e.Become(ctx) })
+	never(t, clock, time.Second, result)
+	if cluster.lock(t, "old") != nil {
+		t.Error("retired legacy lock was acquired")
+	}
+
+	cluster.deletePod(t, "pod-b")
+	if err := await(t, clock, time.Second, result); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if got := cluster.lock(t, "lock").Annotations[legacyRetiredAnnotation]; got != "old" {
+		t.Errorf("new lock marks %q as retired, expected old", got)
+	}
+}
+
+func TestLegacyCandidateRegistersOnBoth(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), testLock("old", "pod-b", nil), testLock("lock", "pod-b", nil))
+	e := newTestElector("lock", cluster, clock, WithLegacyLockName("old"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return e.Become(ctx) })
+	registered := func(name string) bool {
+		for _, c := range lockCandidates(cluster.lock(t, name)) {
+			if c.Identity == "pod-a" {
+				return true
+			}
+		}
+		return false
+	}
+	eventually(t, clock, time.Second, func() bool { return registered("old") && registered("lock") })
+
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package leader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// hashSuffixLength is the number of hex digits of hash appended to names that
+// SanitizeName truncates.
+const hashSuffixLength = 8
+
+// ValidateName returns an error if name can't be used as a lock name because it
+// is not a valid DNS-1123 subdomain.
+func ValidateName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid lock name %q: %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SanitizeName turns an arbitrary string, such as the name of a custom
+// resource, into a valid lock name. It lowercases the string, replaces
+// characters that are not allowed with "-", and trims separators that would
+// otherwise begin or end a dot-separated segment. A name that is too long is
+// truncated, and suffixed with a hash of the original string so that different
+// long names remain distinct.
+func SanitizeName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, name)
+	// each dot-separated label must start and end with an alphanumeric
+	labels := []string{}
+	for _, label := range strings.Split(sanitized, ".") {
+		if label = strings.Trim(label, "-"); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	sanitized = strings.Join(labels, ".")
+
+	if sanitized == "" || len(sanitized) > validation.DNS1123SubdomainMaxLength {
+		sum := sha256.Sum256([]byte(name))
+		suffix := hex.EncodeToString(sum[:])[:hashSuffixLength]
+		max := validation.DNS1123SubdomainMaxLength - len(suffix) - 1
+		if len(sanitized) > max {
+			sanitized = strings.TrimRight(sanitized[:max], "-.")
+		}
+		if sanitized == "" {
+			return suffix
+		}
+		sanitized = sanitized + "-" + suffix
+	}
+	return sanitized
+}
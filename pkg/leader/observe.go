@@ -0,0 +1,192 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// observeRetryInterval is how long Observe waits before trying again after
+// failing to list or watch the lock.
+const observeRetryInterval = time.Second * 1
+
+// Observe watches the named lock and sends a LeaderInfo each time leadership
+// changes, beginning with the current leader. When there is no leader, the
+// LeaderInfo has an empty Holder. The channel is closed once ctx is done.
+//
+// Observe never tries to acquire the lock, so it is safe to use from read-only
+// replicas, UIs and routing layers. If the service account may not list or
+// watch ConfigMaps, the lock is polled instead; see WithPollInterval.
+func Observe(ctx context.Context, name string, opts ...Option) (<-chan LeaderInfo, error) {
+	return NewElector(name, opts...).Observe(ctx)
+}
+
+// Observe behaves like the package-level Observe, using this Elector's
+// namespace and client.
+func (e *Elector) Observe(ctx context.Context) (<-chan LeaderInfo, error) {
+	if err := e.setup(); err != nil {
+		return nil, err
+	}
+	changes := make(chan LeaderInfo, 1)
+	go e.observe(ctx, changes)
+	return changes, nil
+}
+
+// AwaitLeader blocks until the named lock has a leader, which need not be this
+// process, and returns it. It returns ctx.Err() if ctx is done first. Like
+// Observe, it watches the lock rather than polling it, so components that must
+// not start before a leader exists, such as followers that need the leader's
+// endpoint, learn of one promptly.
+func AwaitLeader(ctx context.Context, name string, opts ...Option) (LeaderInfo, error) {
+	return NewElector(name, opts...).AwaitLeader(ctx)
+}
+
+// AwaitLeader behaves like the package-level AwaitLeader, using this Elector's
+// namespace and client.
+func (e *Elector) AwaitLeader(ctx context.Context) (LeaderInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes, err := e.Observe(ctx)
+	if err != nil {
+		return LeaderInfo{}, err
+	}
+	for info := range changes {
+		if info.Holder != "" {
+			return info, nil
+		}
+	}
+	return LeaderInfo{}, ctx.Err()
+}
+
+func (e *Elector) observe(ctx context.Context, changes chan<- LeaderInfo) {
+	defer close(changes)
+
+	var last *LeaderInfo
+	send := func(info LeaderInfo) bool {
+		if last != nil && last.Holder == info.Holder && last.AcquiredAt.Equal(info.AcquiredAt) && last.Epoch == info.Epoch {
+			return true
+		}
+		last = &info
+		select {
+		case changes <- info:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	retry := func() bool {
+		select {
+		case <-e.sleeper.After(observeRetryInterval):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	selector := fields.OneTermEqualSelector("metadata.name", e.name).String()
+	for {
+		list, err := cms.List(metav1.ListOptions{FieldSelector: selector})
+		if apierrors.IsForbidden(err) {
+			e.observePolling(ctx, send)
+			return
+		}
+		if err != nil {
+			e.log().Warnf("failed to list lock: %s", err.Error())
+			if !retry() {
+				return
+			}
+			continue
+		}
+		info := LeaderInfo{Lock: e.name, Namespace: e.ns}
+		if len(list.Items) > 0 {
+			info = leaderInfo(&list.Items[0], e.clock.Now())
+		}
+		if !send(info) {
+			return
+		}
+
+		watcher, err := cms.Watch(metav1.ListOptions{
+			FieldSelector:   selector,
+			ResourceVersion: list.ResourceVersion,
+		})
+		if apierrors.IsForbidden(err) {
+			e.observePolling(ctx, send)
+			return
+		}
+		if err != nil {
+			e.log().Warnf("failed to watch lock: %s", err.Error())
+			if !retry() {
+				return
+			}
+			continue
+		}
+		if !e.observeEvents(ctx, watcher, send) {
+			return
+		}
+	}
+}
+
+// observePolling is the degraded form of observe for service accounts that
+// may not list or watch ConfigMaps: it gets the lock every poll interval, as
+// set with WithPollInterval.
+func (e *Elector) observePolling(ctx context.Context, send func(LeaderInfo) bool) {
+	e.log().Warnf("not allowed to list or watch the lock; polling every %s", e.pollInterval)
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	for {
+		cm, err := cms.Get(e.name, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			if !send(leaderInfo(cm, e.clock.Now())) {
+				return
+			}
+		case apierrors.IsNotFound(err):
+			if !send(LeaderInfo{Lock: e.name, Namespace: e.ns}) {
+				return
+			}
+		default:
+			e.log().Warnf("failed to get lock: %s", err.Error())
+		}
+		select {
+		case <-e.sleeper.After(e.pollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// observeEvents passes leadership changes from the watch to send until the
+// watch ends, and returns false if observing should stop.
+func (e *Elector) observeEvents(ctx context.Context, watcher watch.Interface, send func(LeaderInfo) bool) bool {
+	defer watcher.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return true
+			}
+			cm, isConfigMap := event.Object.(*corev1.ConfigMap)
+			switch {
+			case event.Type == watch.Error:
+				return true
+			case !isConfigMap:
+				continue
+			case event.Type == watch.Deleted:
+				if !send(LeaderInfo{Lock: e.name, Namespace: e.ns}) {
+					return false
+				}
+			default:
+				if !send(leaderInfo(cm, e.clock.Now())) {
+					return false
+				}
+			}
+		}
+	}
+}
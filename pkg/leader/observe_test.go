@@ -0,0 +1,154 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testPollInterval = time.Second * 5
+
+// forbidWatch makes listing and watching ConfigMaps forbidden, as for a
+// service account that may only get them.
+func forbidWatch(cluster *fakeCluster) {
+	forbid := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(configMapsResource.GroupResource(), "", nil)
+	}
+	cluster.PrependReactor("list", "configmaps", forbid)
+	cluster.PrependWatchReactor("configmaps", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		return true, nil, apierrors.NewForbidden(configMapsResource.GroupResource(), "", nil)
+	})
+}
+
+// awaitHolder waits for changes to report holder, advancing clock by step
+// each time it checks, and returns what it reported.
+func awaitHolder(t *testing.T, clock *fakeClock, step time.Duration, changes <-chan LeaderInfo, holder string) LeaderInfo {
+	t.Helper()
+	var info LeaderInfo
+	eventually(t, clock, step, func() bool {
+		select {
+		case got, ok := <-changes:
+			if !ok {
+				t.Fatal("changes closed early")
+			}
+			info = got
+			return info.Holder == holder
+		default:
+			return false
+		}
+	})
+	return info
+}
+
+func TestObserve(t *testing.T) {
+	tests := []struct {
+		name string
+		// polling, if set, forbids listing and watching the lock
+		polling bool
+	}{
+		{
+			name: "watch",
+		},
+		{
+			name:    "polling",
+			polling: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"))
+			if tc.polling {
+				forbidWatch(cluster)
+			}
+			observer := newTestElector("lock", cluster, clock, WithHostname("pod-b"), WithPollInterval(testPollInterval))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			changes, err := observer.Observe(ctx)
+			if err != nil {
+				t.Fatalf("Observe failed: %s", err.Error())
+			}
+			first := awaitHolder(t, clock, testPollInterval, changes, "")
+			if first.Lock != "lock" || first.Namespace != testNamespace {
+				t.Errorf("first change is %+v, expected lock in %s", first, testNamespace)
+			}
+			if !tc.polling {
+				eventually(t, clock, 0, func() bool { return cluster.watching() > 0 })
+			}
+
+			e := newTestElector("lock", cluster, clock)
+			if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+			if info := awaitHolder(t, clock, testPollInterval, changes, "pod-a"); info.HolderKind != "Pod" || info.Epoch != 1 {
+				t.Errorf("change is %+v, expected a pod with epoch 1", info)
+			}
+
+			if err := e.Release(context.Background()); err != nil {
+				t.Fatalf("Release failed: %s", err.Error())
+			}
+			awaitHolder(t, clock, testPollInterval, changes, "")
+
+			cancel()
+			eventually(t, clock, testPollInterval, func() bool {
+				_, ok := <-changes
+				return !ok
+			})
+		})
+	}
+}
+
+func TestAwaitLeader(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	observer := newTestElector("lock", cluster, clock, WithHostname("pod-b"))
+
+	var info LeaderInfo
+	result := async(func() error {
+		var err error
+		info, err = observer.AwaitLeader(context.Background())
+		return err
+	})
+	eventually(t, clock, 0, func() bool { return cluster.watching() > 0 })
+	never(t, clock, time.Second, result)
+
+	e := newTestElector("lock", cluster, clock)
+	if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if err := await(t, clock, 0, result); err != nil {
+		t.Fatalf("AwaitLeader failed: %s", err.Error())
+	}
+	if info.Holder != "pod-a" {
+		t.Errorf("leader is %q, expected pod-a", info.Holder)
+	}
+
+	// a leader that already exists is returned at once
+	if info, err := observer.AwaitLeader(context.Background()); err != nil || info.Holder != "pod-a" {
+		t.Errorf("AwaitLeader returned %q, %v; expected pod-a", info.Holder, err)
+	}
+}
+
+func TestAwaitLeaderCanceled(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	observer := newTestElector("lock", cluster, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := async(func() error {
+		_, err := observer.AwaitLeader(ctx)
+		return err
+	})
+	never(t, clock, time.Second, result)
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
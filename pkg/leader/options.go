@@ -0,0 +1,504 @@
+package leader
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option configures an Elector.
+type Option func(*Elector)
+
+// WithNamespace sets the namespace in which the lock is created. By default
+// the namespace is taken from the OPERATOR_NAMESPACE or WATCH_NAMESPACE
+// environment variables, or else is the namespace of the current pod.
+func WithNamespace(ns string) Option {
+	return func(e *Elector) {
+		e.ns = ns
+	}
+}
+
+// WithClient sets the client used to talk to the Kubernetes API. By default a
+// client is created from the in-cluster config.
+func WithClient(client k8sclient.Interface) Option {
+	return func(e *Elector) {
+		e.client = client
+	}
+}
+
+// WithOwner sets the owner of the lock, instead of looking up the current pod.
+// The lock is deleted by the garbage collector when the owner is deleted, and
+// is held under the owner's name. It is short for WithIdentityProvider with
+// StaticIdentity.
+func WithOwner(owner metav1.OwnerReference) Option {
+	return WithIdentityProvider(StaticIdentity(owner.Name, owner))
+}
+
+// WithIdentity sets the identity this Elector holds the lock under, instead of
+// looking up the current pod. This lets processes that do not run in a pod,
+// such as VMs or other external processes, take part in the same election as
+// pods. It must be combined with WithLease, since such a lock has no owner for
+// the garbage collector to follow; out of a cluster, WithNamespace and
+// WithClient are needed too. Identities must be unique among candidates. It is
+// short for WithIdentityProvider with StaticIdentity and no owner.
+func WithIdentity(identity string) Option {
+	return WithIdentityProvider(StaticIdentity(identity, metav1.OwnerReference{}))
+}
+
+// WithDedupeWindow coalesces notifications of a transition of the lock, such as
+// one term of leadership being acquired by one holder, that repeat within
+// window in this process without any other transition in between. In practice
+// this suppresses repeats when a term is resumed, as by a repeated Become or
+// by Electors of the same lock made one after another. Acquisition and loss
+// hooks, their metrics and pushes to a Pushgateway are then sent once per
+// burst. The Elector emits no Kubernetes Events, and the audit log records
+// every decision regardless. Observe needs no such setting, since it only ever
+// sends changes. By default nothing is coalesced.
+func WithDedupeWindow(window time.Duration) Option {
+	return func(e *Elector) {
+		e.dedupeWindow = window
+	}
+}
+
+// WithAuditLog makes the Elector append a JSON record to w, one per line, for
+// each decision it makes: attempts, holders observed, takeovers, acquisitions,
+// releases, losses and errors. This gives an audit trail of the election that
+// does not depend on log retention. Writes happen synchronously as events
+// occur, so w should not block; write errors are ignored. Several Electors may
+// share one writer.
+func WithAuditLog(w io.Writer) Option {
+	return func(e *Elector) {
+		e.auditLog = w
+	}
+}
+
+// WithStandby sets a function to call if leadership has not been acquired
+// within after, with the holder of the lock as last observed. The campaign
+// continues in the background, so an active/standby service can do degraded
+// work, such as serving reads, while it waits. The function is called once per
+// campaign, in the same way as Hooks; use OnAcquired to learn when the
+// standby period ends.
+func WithStandby(after time.Duration, onStandby func(holder string)) Option {
+	return func(e *Elector) {
+		e.standbyAfter = after
+		e.onStandby = onStandby
+	}
+}
+
+// WithMutateLock sets a function that may adjust the lock before it is
+// created, for clusters whose policies require extra owner references, labels,
+// annotations or other fields. The object is a *corev1.ConfigMap, so data
+// entries may be added too. Afterwards, the fields the election depends on,
+// including its name, namespace, owner, label and annotations, are set again.
+// The function is not called when an existing lock is taken over.
+func WithMutateLock(mutate func(obj metav1.Object)) Option {
+	return func(e *Elector) {
+		e.mutateLock = mutate
+	}
+}
+
+// DefaultPodNameFile is where the pod name is read from, by default, when no
+// pod matches the hostname. It matches the path commonly used when exposing
+// metadata.name with a downward API volume.
+const DefaultPodNameFile = "/etc/podinfo/name"
+
+// WithPodNameFile sets the file from which the pod name is read when no pod
+// matches the hostname, as happens with some StatefulSets and on Windows nodes.
+// An empty path disables the fallback. Like WithHostname, it configures the
+// default HostnameIdentity provider.
+func WithPodNameFile(path string) Option {
+	return func(e *Elector) {
+		e.podNameFile = path
+	}
+}
+
+// WithHostname sets the name used to look up the current pod, instead of the
+// hostname reported by the OS. This is useful where the hostname differs from
+// the pod name.
+func WithHostname(hostname string) Option {
+	return func(e *Elector) {
+		e.hostname = hostname
+	}
+}
+
+// WithPeerPort records the port on which this process accepts connections from
+// its peers on the lock, alongside its pod's IP address, while it is the
+// leader. Followers can find the leader with NewPeerResolver.
+func WithPeerPort(port int) Option {
+	return func(e *Elector) {
+		e.peerPort = port
+	}
+}
+
+// WithPodAnnotations annotates the current pod while it is the leader, with
+// "leader.of/<lock>" set to "true" and "leader.since/<lock>" set to when
+// leadership was acquired, so that kubectl users and dashboards can see which
+// replica leads. The annotations are removed when leadership ends. This has no
+// effect when the lock is owned by an object provided with WithOwner.
+func WithPodAnnotations() Option {
+	return func(e *Elector) {
+		e.podAnnotations = true
+	}
+}
+
+// WithReadinessGate sets a pod condition of the provided type on the current
+// pod, true while it is the leader and false otherwise. With a matching
+// readiness gate in the pod spec, as produced by ReadinessGateSnippet, the pod
+// is ready only while it leads, so Service endpoints include only the leader.
+// This has no effect when the lock is owned by an object provided with
+// WithOwner.
+func WithReadinessGate(conditionType string) Option {
+	return func(e *Elector) {
+		e.readinessGate = conditionType
+	}
+}
+
+// WithLegacyLockName eases renaming a lock. Before trying for its own lock,
+// the Elector acquires the lock with the legacy name too, and holds both. This
+// keeps replicas that still use the legacy name from becoming the leader
+// alongside it during a rollout. Candidates waiting for the legacy lock record
+// themselves on both locks, and while monitoring its lock, the leader deletes
+// the legacy lock once every candidate recorded on it is also recorded on the
+// new one. The new lock is then marked so that candidates no longer acquire
+// the legacy lock. Replicas that do not record themselves as candidates can't
+// be told apart, so the legacy lock may be deleted while they still use it.
+// Once no replica uses the legacy name, the option can be dropped.
+func WithLegacyLockName(name string) Option {
+	return func(e *Elector) {
+		e.legacyName = name
+	}
+}
+
+// WithFinalizer places a finalizer on the lock, so that during a voluntary
+// Release the lock is not deleted until all cleanup hooks have succeeded.
+func WithFinalizer() Option {
+	return func(e *Elector) {
+		e.finalizer = true
+	}
+}
+
+// WithCleanupHook registers a function to run during a voluntary Release,
+// before the lock is deleted. Hooks run in the order they were registered, and
+// are a good place to flush state or close external leases before another pod
+// takes over.
+func WithCleanupHook(hook func(ctx context.Context) error) Option {
+	return func(e *Elector) {
+		e.cleanupHooks = append(e.cleanupHooks, hook)
+	}
+}
+
+// WithTerminatingLeaderSlack allows taking over the lock from a leader whose
+// pod is terminating but has not yet gone away. Once the pod's deletion
+// timestamp, which accounts for its termination grace period, plus slack has
+// passed, the lock is deleted and a new election proceeds. By default a
+// terminating leader is waited on for as long as it exists.
+func WithTerminatingLeaderSlack(slack time.Duration) Option {
+	return func(e *Elector) {
+		e.takeOverTerminating = true
+		e.terminatingSlack = slack
+	}
+}
+
+// WithHooks sets functions to be called at each stage of the election.
+func WithHooks(hooks Hooks) Option {
+	return func(e *Elector) {
+		e.hooks = hooks
+	}
+}
+
+// WithMetricsServer serves the election metrics on /metrics at addr, such as
+// ":8383", for deployments that don't already expose a Prometheus endpoint.
+// Electors given the same address share a server. Processes that do have their
+// own endpoint should use RegisterMetrics instead.
+func WithMetricsServer(addr string) Option {
+	return func(e *Elector) {
+		e.metricsAddr = addr
+	}
+}
+
+// WithPushgateway pushes the election metrics to the Prometheus Pushgateway at
+// url, such as "http://pushgateway:9091", whenever leadership is acquired or
+// ends. This suits Jobs and other short-lived processes, which may exit before
+// they are scraped. Metrics are pushed under job, grouped by lock and identity
+// so that candidates do not overwrite each other. Failed pushes are logged.
+func WithPushgateway(url, job string) Option {
+	return func(e *Elector) {
+		e.pushURL = url
+		e.pushJob = job
+	}
+}
+
+// OutagePolicy determines what a leader does when it can't reach the API to
+// check that it still holds its lock.
+type OutagePolicy int
+
+const (
+	// FailOpen keeps acting as the leader for as long as the API is
+	// unreachable. This is the default, and suits workloads for which a pause
+	// in leadership is worse than a brief overlap.
+	FailOpen OutagePolicy = iota
+	// FailClosed gives up leadership once the API has been unreachable for
+	// the tolerance window.
+	FailClosed
+)
+
+// WithOutagePolicy sets what happens when the API becomes unreachable after
+// leadership is acquired. With FailClosed, leadership is considered lost once
+// the lock could not be checked for the duration of tolerance; the lock itself
+// is left in place, so leadership resumes if the API recovers before another
+// pod takes over.
+func WithOutagePolicy(policy OutagePolicy, tolerance time.Duration) Option {
+	return func(e *Elector) {
+		e.outagePolicy = policy
+		e.outageTolerance = tolerance
+	}
+}
+
+// WithClock sets the Clock used to tell the time. It is meant for tests.
+func WithClock(clock Clock) Option {
+	return func(e *Elector) {
+		e.clock = clock
+	}
+}
+
+// WithSleeper sets the Sleeper used to wait between attempts and checks. It is
+// meant for tests.
+func WithSleeper(sleeper Sleeper) Option {
+	return func(e *Elector) {
+		e.sleeper = sleeper
+	}
+}
+
+// WithoutPreflight skips checking, before campaigning, that the service account
+// has the permissions the election needs.
+func WithoutPreflight() Option {
+	return func(e *Elector) {
+		e.skipPreflight = true
+	}
+}
+
+// WithLockTTL stamps the lock with an expiry that the leader refreshes, for
+// clusters where garbage collection of the lock is unreliable. Candidates
+// treat a lock whose expiry has gone unrefreshed for ttl, as they observe it,
+// as abandoned, even if its owner still exists. The leader refreshes the lock
+// several times per TTL, whether it became the leader with Become or Run, and
+// gives up leadership if it can't refresh the lock before candidates could
+// take it over, as with WithLease.
+func WithLockTTL(ttl time.Duration) Option {
+	return func(e *Elector) {
+		e.lockTTL = ttl
+	}
+}
+
+// WithJobOwner makes the lock owned by the Job that controls the current pod,
+// rather than by the pod itself, so that the lock lives exactly as long as the
+// Job. The pod that holds the lock is still the leader; once it has completed,
+// other candidates treat the lock as stale. A lock owned by the Job of a
+// CronJob goes away when the CronJob's history limit removes the Job.
+func WithJobOwner() Option {
+	return func(e *Elector) {
+		e.jobOwner = true
+	}
+}
+
+// WithOrdinalPreference prefers StatefulSet pods with lower ordinals as the
+// leader. Before each attempt to create the lock, a pod waits its ordinal times
+// step, so when the lock becomes free, pod 0 tries right away, pod 1 after
+// step, and so on. This biases placement deterministically without preempting
+// an existing leader. Pods not managed by a StatefulSet are unaffected.
+func WithOrdinalPreference(step time.Duration) Option {
+	return func(e *Elector) {
+		e.ordinalStep = step
+	}
+}
+
+// WithZonePreference biases leadership toward a zone, based on the topology
+// labels of the nodes candidates run on. Determining the zone requires
+// permission to get nodes.
+func WithZonePreference(pref ZonePreference) Option {
+	return func(e *Elector) {
+		e.zonePreference = &pref
+	}
+}
+
+// WithReleaseOnCancel makes the Elector delete the lock, on a best-effort
+// basis, when the context passed to Become, Run or BecomeAndRun is cancelled
+// after leadership was acquired. Run and BecomeAndRun do so before returning;
+// Become, which has already returned, does so in the background. Without it,
+// the lock is left for the garbage collector or a TTL to remove.
+func WithReleaseOnCancel() Option {
+	return func(e *Elector) {
+		e.releaseOnCancel = true
+	}
+}
+
+// WithDryRun makes Become and Run report, via the log, what they would do
+// instead of doing it, and then return ErrDryRun. See DryRun.
+func WithDryRun() Option {
+	return func(e *Elector) {
+		e.dryRun = true
+	}
+}
+
+// WithReleaseOnReturn makes BecomeAndRun release the lock once its function
+// returns, so that another candidate can take over right away.
+func WithReleaseOnReturn() Option {
+	return func(e *Elector) {
+		e.releaseOnReturn = true
+	}
+}
+
+// WithLeaderLabel sets the provided label on the current pod while it is the
+// leader, and removes it when leadership ends, so that a Service selecting on
+// the label routes traffic only to the leader. On acquiring leadership, the
+// label is also removed from any other pod that still has it. This has no
+// effect when the lock is owned by an object provided with WithOwner.
+func WithLeaderLabel(key, value string) Option {
+	return func(e *Elector) {
+		e.leaderLabelKey = key
+		e.leaderLabelValue = value
+	}
+}
+
+// WithSafeStepDown makes Release and TransferTo wait up to the provided time
+// for a healthy standby before giving up the lock, and refuse with
+// ErrNoStandby if none becomes available. A standby is healthy if it was
+// recently recorded on the lock as a candidate and its pod is ready. A step-down
+// is also held back while a PodDisruptionBudget covering the current pod allows
+// no disruptions. This avoids leaderless windows when stepping down during a
+// node drain.
+func WithSafeStepDown(wait time.Duration) Option {
+	return func(e *Elector) {
+		e.safeStepDown = true
+		e.stepDownWait = wait
+	}
+}
+
+// WithRolloutStepDown makes the leader step down in favor of a waiting
+// candidate that runs a newer revision of the same Deployment, once that
+// candidate's pod is ready. Leadership then moves to the new version early in
+// a rolling update, shortening the time that old and new versions run
+// together. Leadership is handed over as with TransferTo.
+func WithRolloutStepDown() Option {
+	return func(e *Elector) {
+		e.rolloutStepDown = true
+	}
+}
+
+// WithLease represents leadership as a lease in the lock's data instead of by
+// the lock's existence. The leader records itself and a renewal time, and
+// renews the lease several times per duration; a candidate takes over a lease
+// that has gone unchanged for the duration. All updates are compare-and-swap
+// on the lock's resourceVersion. The lock has no owner reference, so this does
+// not rely on garbage collection, for clusters where it is disabled or slow.
+func WithLease(duration time.Duration) Option {
+	return func(e *Elector) {
+		e.leaseDuration = duration
+	}
+}
+
+// WithPollInterval sets how often the lock and the leader's pod are checked
+// where the service account lacks the permissions needed to watch them, as
+// found when the election starts. Such elections, Observe and Managers poll
+// instead, so they still notice a departed leader, though later than a watch
+// would. It defaults to 5 seconds.
+func WithPollInterval(interval time.Duration) Option {
+	return func(e *Elector) {
+		e.pollInterval = interval
+	}
+}
+
+// WithBackends sets an ordered list of backends for the lock. Before its first
+// attempt, the Elector uses the first backend for which the service account has
+// every permission it needs, so the same binary works across clusters with
+// differing permissions. Only permissions are considered: every backend stores
+// its lock in a ConfigMap, so none depends on the API versions a cluster
+// serves, and none uses coordination.k8s.io Leases. BackendConfigMapLease uses
+// the duration set with WithLease, or 15 seconds. The backend in use is
+// reported by Status. If no backend qualifies, Become returns the
+// *PermissionError for the first.
+func WithBackends(backends ...Backend) Option {
+	return func(e *Elector) {
+		e.backends = backends
+	}
+}
+
+// WithStartupJitter makes the Elector wait for a random time, up to max, before
+// its first attempt to become the leader. When many replicas restart at once,
+// as after a node reboot or during a rollout, this spreads their requests to
+// the API server over time. By default there is no delay.
+func WithStartupJitter(max time.Duration) Option {
+	return func(e *Elector) {
+		e.startupJitter = max
+	}
+}
+
+// WithBackoff sets the rate limiter that paces attempts to become the leader
+// while another pod holds the lock. Its When method is called with the lock's
+// name before each retry, and Forget once leadership is acquired. By default,
+// the delay starts at one second and doubles after each attempt, up to 16
+// seconds.
+func WithBackoff(limiter workqueue.RateLimiter) Option {
+	return func(e *Elector) {
+		e.backoff = limiter
+	}
+}
+
+// LossPolicy decides what happens when an Elector finds that leadership it
+// held has been lost, as detected while monitoring the lock with Run,
+// WithLockTTL or WithLease. It is called with the lock's name, synchronously
+// and before the loss is acted on in any other way. Unlike Hooks.OnLost, it is
+// not called when leadership is given up with Release.
+type LossPolicy func(lock string)
+
+// WithLossPolicy sets the policy applied when leadership is lost. By default,
+// nothing beyond the usual bookkeeping happens: the Gate closes, Status reports
+// a candidate, and Run cancels its function and campaigns again.
+func WithLossPolicy(policy LossPolicy) Option {
+	return func(e *Elector) {
+		e.lossPolicy = policy
+	}
+}
+
+// CancelOnLoss returns a LossPolicy that calls cancel, for processes that
+// derive the context of their leader-only work from one the policy can cancel.
+func CancelOnLoss(cancel context.CancelFunc) LossPolicy {
+	return func(lock string) {
+		cancel()
+	}
+}
+
+// ExitOnLoss returns a LossPolicy that exits the process with the provided
+// code, for processes that can't safely stop their leader-only work any other
+// way.
+func ExitOnLoss(code int) LossPolicy {
+	return func(lock string) {
+		logrus.WithField("lock", lock).Errorf("leadership lost; exiting with code %d", code)
+		os.Exit(code)
+	}
+}
+
+// WithIdentityConflictDetection makes the Elector check that no other live
+// process uses its identity, as happens when two processes run in one pod or
+// hostnames collide. Such a process is reported with an
+// *IdentityConflictError, by Become while campaigning and by Run and
+// BecomeAndRun while leading.
+//
+// To tell a duplicate from its own restarted self, the leader records a
+// heartbeat on the lock every 10 seconds, which requires permission to update
+// ConfigMaps, and Become waits up to 25 seconds before resuming a lock that
+// another instance recorded a heartbeat on. Become keeps checking in the
+// background for as long as the process leads. By default none of this
+// happens, and a lock held under this Elector's identity is resumed at once.
+func WithIdentityConflictDetection() Option {
+	return func(e *Elector) {
+		e.detectConflicts = true
+	}
+}
@@ -0,0 +1,69 @@
+package leader
+
+import (
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoController indicates that the current pod is not managed by a
+// controller, so no stable lock name can be derived from its owners.
+var ErrNoController = errors.New("pod has no controlling owner")
+
+// DefaultLockName derives a lock name from the top-most controller of the
+// current pod, such as "myapp-lock" for a pod managed by a ReplicaSet of the
+// "myapp" Deployment. This lets several operators share a namespace without
+// choosing lock names by hand.
+func DefaultLockName() (string, error) {
+	ns, err := myNS(logrus.StandardLogger())
+	if err != nil {
+		return "", err
+	}
+	client, err := getClientset()
+	if err != nil {
+		return "", err
+	}
+	pod, err := myPod(logrus.StandardLogger(), client, ns, "", DefaultPodNameFile)
+	if err != nil {
+		return "", err
+	}
+	chain, err := OwnerChain(client, pod)
+	if err != nil {
+		return "", err
+	}
+	return LockNameForChain(chain)
+}
+
+// LockNameForChain returns the lock name derived from an owner chain as
+// returned by OwnerChain.
+func LockNameForChain(chain []metav1.OwnerReference) (string, error) {
+	if len(chain) == 0 {
+		return "", ErrNoController
+	}
+	return chain[len(chain)-1].Name + "-lock", nil
+}
+
+// OwnerChain returns the chain of controllers that manage the provided pod,
+// starting with the pod's own controller. ReplicaSets are followed to the
+// Deployment that controls them, so a typical result is [ReplicaSet,
+// Deployment]. The chain is empty if the pod has no controller.
+func OwnerChain(client k8sclient.Interface, pod *corev1.Pod) ([]metav1.OwnerReference, error) {
+	chain := []metav1.OwnerReference{}
+	ref := metav1.GetControllerOf(pod)
+	for ref != nil {
+		chain = append(chain, *ref)
+		if ref.Kind != "ReplicaSet" {
+			break
+		}
+		rs, err := client.AppsV1().ReplicaSets(pod.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		ref = metav1.GetControllerOf(rs)
+	}
+	return chain, nil
+}
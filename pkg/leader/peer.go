@@ -0,0 +1,80 @@
+package leader
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// LeaderEndpoint is where the leader of a lock accepts connections from its
+// peers.
+type LeaderEndpoint struct {
+	// Holder is the identity of the leader.
+	Holder string
+	// IP is the IP address of the leader's pod.
+	IP string
+	// Port is the port set by the leader with WithPeerPort.
+	Port int
+}
+
+// String returns the endpoint as host:port.
+func (l LeaderEndpoint) String() string {
+	return net.JoinHostPort(l.IP, strconv.Itoa(l.Port))
+}
+
+// PeerResolver tracks the endpoint of a lock's leader, as it changes, for
+// followers that connect to the leader directly.
+type PeerResolver struct {
+	mu       sync.Mutex
+	endpoint LeaderEndpoint
+	known    bool
+	changed  chan struct{}
+}
+
+// NewPeerResolver returns a PeerResolver that follows the leader of the named
+// lock, until ctx is done. It observes the lock as with Observe.
+func NewPeerResolver(ctx context.Context, name string, opts ...Option) (*PeerResolver, error) {
+	changes, err := Observe(ctx, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r := &PeerResolver{changed: make(chan struct{})}
+	go func() {
+		for info := range changes {
+			r.update(info)
+		}
+	}()
+	return r, nil
+}
+
+// Endpoint returns the current leader's endpoint, and false if there is no
+// leader or it did not publish an endpoint.
+func (r *PeerResolver) Endpoint() (LeaderEndpoint, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.endpoint, r.known
+}
+
+// Changed returns a channel that is closed the next time the endpoint changes,
+// so that connections to a previous leader can be closed.
+func (r *PeerResolver) Changed() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.changed
+}
+
+func (r *PeerResolver) update(info LeaderInfo) {
+	endpoint := LeaderEndpoint{Holder: info.Holder, IP: info.Address, Port: info.Port}
+	known := info.Holder != "" && info.Address != "" && info.Port > 0
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if endpoint == r.endpoint && known == r.known {
+		return
+	}
+	r.endpoint = endpoint
+	r.known = known
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
@@ -0,0 +1,127 @@
+package leader
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// statefulSetOrdinal returns the ordinal of a pod managed by a StatefulSet,
+// which is the numeric suffix of its name, or -1 for any other pod.
+func statefulSetOrdinal(pod *corev1.Pod) int {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil || ref.Kind != "StatefulSet" {
+		return -1
+	}
+	i := strings.LastIndex(pod.Name, "-")
+	if i < 0 {
+		return -1
+	}
+	ordinal, err := strconv.Atoi(pod.Name[i+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+// attemptDelay returns how long to wait before each attempt to create the
+// lock, so that preferred candidates get the first chance at it.
+func (e *Elector) attemptDelay() time.Duration {
+	var delay time.Duration
+	if e.ordinalStep > 0 && e.ordinal > 0 {
+		delay += time.Duration(e.ordinal) * e.ordinalStep
+	}
+	if e.zonePreference != nil && !e.inPreferredZone() {
+		delay += e.zonePreference.Delay
+	}
+	return delay
+}
+
+// Node labels that identify the zone, current and deprecated.
+const (
+	zoneLabel           = "topology.kubernetes.io/zone"
+	deprecatedZoneLabel = "failure-domain.beta.kubernetes.io/zone"
+)
+
+// zoneAnnotation records the zone of the leader's node on the lock.
+const zoneAnnotation = "leader.mhrivnak.github.io/zone"
+
+// ZonePreference biases which zone the leader runs in. Candidates outside the
+// preferred zone wait an extra Delay before each attempt to create the lock.
+type ZonePreference struct {
+	// Zone is the preferred zone. If empty, and PreferPreviousLeaderZone is
+	// set, the zone of the most recently observed leader is preferred.
+	Zone string
+	// PreferPreviousLeaderZone prefers the zone of the most recently
+	// observed leader when Zone is empty, for example to keep the leader
+	// close to state it built up.
+	PreferPreviousLeaderZone bool
+	// Delay is how long candidates outside the preferred zone wait.
+	Delay time.Duration
+}
+
+// inPreferredZone returns true if the current pod runs in the preferred zone,
+// or if no zone is currently preferred.
+func (e *Elector) inPreferredZone() bool {
+	preferred := e.zonePreference.Zone
+	if preferred == "" && e.zonePreference.PreferPreviousLeaderZone {
+		preferred = e.lastLeaderZone
+	}
+	return preferred == "" || preferred == e.zone
+}
+
+// nodeZone returns the zone of the node the provided pod runs on, or an empty
+// string if it can't be determined.
+func (e *Elector) nodeZone(pod *corev1.Pod) string {
+	if pod.Spec.NodeName == "" {
+		return ""
+	}
+	node, err := e.client.CoreV1().Nodes().Get(pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		e.log().Warnf("failed to get node to determine zone: %s", err.Error())
+		return ""
+	}
+	if zone, ok := node.Labels[zoneLabel]; ok {
+		return zone
+	}
+	return node.Labels[deprecatedZoneLabel]
+}
+
+// delayAttempt waits for attemptDelay, returning ctx.Err() if ctx is done
+// first.
+func (e *Elector) delayAttempt(ctx context.Context) error {
+	delay := e.attemptDelay()
+	if delay <= 0 {
+		return nil
+	}
+	e.log().Debugf("waiting %s before trying to create the lock", delay)
+	select {
+	case <-e.sleeper.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startupDelay waits for a random time up to the jitter set with
+// WithStartupJitter, the first time it is called. It returns ctx.Err() if ctx
+// is done first.
+func (e *Elector) startupDelay(ctx context.Context) error {
+	if e.startupJitter <= 0 || e.jittered {
+		return nil
+	}
+	e.jittered = true
+	delay := time.Duration(rand.Int63n(int64(e.startupJitter)))
+	e.log().Debugf("waiting %s before starting the election", delay)
+	select {
+	case <-e.sleeper.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package leader
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// WorkerPool runs a fixed number of workers that process items from a
+// workqueue, but only while this pod is the leader. When leadership is lost,
+// workers finish the item they are processing and stop; items still in the
+// queue wait there until leadership is regained.
+type WorkerPool struct {
+	queue   workqueue.Interface
+	workers int
+	process func(ctx context.Context, item interface{})
+}
+
+// NewWorkerPool returns a WorkerPool that runs the provided number of workers,
+// each passing items from queue to process. The context passed to process is
+// cancelled when leadership is lost. process does not need to call
+// queue.Done; the pool does that once process returns.
+func NewWorkerPool(queue workqueue.Interface, workers int, process func(ctx context.Context, item interface{})) *WorkerPool {
+	return &WorkerPool{
+		queue:   queue,
+		workers: workers,
+		process: process,
+	}
+}
+
+// Run campaigns for leadership using e, and runs the workers whenever it is
+// held. It returns when ctx is done, after all workers have stopped, or when an
+// error prevents campaigning. The queue is not shut down.
+func (p *WorkerPool) Run(ctx context.Context, e *Elector) error {
+	return e.run(ctx, func(ctx context.Context) {
+		var wg sync.WaitGroup
+		for i := 0; i < p.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.work(ctx)
+			}()
+		}
+
+		<-ctx.Done()
+		// wake any workers that are blocked waiting for an item
+		for i := 0; i < p.workers; i++ {
+			p.queue.Add(&pause{})
+		}
+		wg.Wait()
+	})
+}
+
+// pause is added to the queue to wake up workers when leadership is lost. Each
+// one is a distinct pointer so that the queue does not de-duplicate them, which
+// requires a non-zero size.
+type pause struct{ _ byte }
+
+func (p *WorkerPool) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		item, shutdown := p.queue.Get()
+		if shutdown {
+			return
+		}
+		if _, ok := item.(*pause); ok {
+			// possibly left over from a previous term; discard it
+			p.queue.Done(item)
+			continue
+		}
+		if ctx.Err() != nil {
+			// hand the item back for the next term. Adding it while it is
+			// still being processed re-queues it once Done is called.
+			p.queue.Add(item)
+			p.queue.Done(item)
+			return
+		}
+		p.process(ctx, item)
+		p.queue.Done(item)
+	}
+}
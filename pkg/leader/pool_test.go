@@ -0,0 +1,105 @@
+package leader
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// receive waits for n items from ch, advancing clock by step each time it
+// checks, and returns them in order.
+func receive(t *testing.T, clock *fakeClock, step time.Duration, ch <-chan string, n int) []string {
+	t.Helper()
+	items := []string{}
+	eventually(t, clock, step, func() bool {
+		select {
+		case item := <-ch:
+			items = append(items, item)
+		default:
+		}
+		return len(items) == n
+	})
+	sort.Strings(items)
+	return items
+}
+
+// nothingFrom checks that ch does not receive while clock is advanced by step
+// a few times.
+func nothingFrom(t *testing.T, clock *fakeClock, step time.Duration, ch <-chan string) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Millisecond * 5)
+		select {
+		case item := <-ch:
+			t.Fatalf("processed %s while not the leader", item)
+		default:
+		}
+		clock.Step(step)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), testPod("pod-c"), testLock("lock", "pod-b", nil))
+	e := newTestElector("lock", cluster, clock)
+
+	queue := workqueue.New()
+	defer queue.ShutDown()
+	processed := make(chan string, 10)
+	pool := NewWorkerPool(queue, 2, func(ctx context.Context, item interface{}) {
+		if item == "slow" {
+			// in progress when leadership is lost
+			processed <- "slow started"
+			<-ctx.Done()
+		}
+		processed <- item.(string)
+	})
+	queue.Add("a")
+	queue.Add("b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return pool.Run(ctx, e) })
+
+	// nothing is processed while pod-b leads
+	nothingFrom(t, clock, time.Second, processed)
+	if got := queue.Len(); got != 2 {
+		t.Errorf("queue holds %d items, expected 2", got)
+	}
+
+	// once pod-b is gone, the workers start
+	cluster.deletePod(t, "pod-b")
+	if got := receive(t, clock, time.Second, processed, 2); got[0] != "a" || got[1] != "b" {
+		t.Errorf("processed %v, expected a and b", got)
+	}
+	queue.Add("slow")
+	receive(t, clock, 0, processed, 1)
+
+	// pod-c takes over; the item in progress is finished, and later items
+	// wait for the next term
+	if err := cluster.tracker.Delete(configMapsResource, testNamespace, "lock"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.tracker.Add(testLock("lock", "pod-c", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, clock, lockCheckInterval, processed, 1); got[0] != "slow" {
+		t.Errorf("processed %v, expected slow", got)
+	}
+	eventually(t, clock, lockCheckInterval, func() bool { return e.Status().State == StateCandidate })
+	queue.Add("c")
+	nothingFrom(t, clock, time.Second, processed)
+
+	cluster.deletePod(t, "pod-c")
+	if got := receive(t, clock, time.Second, processed, 1); got[0] != "c" {
+		t.Errorf("processed %v, expected c", got)
+	}
+
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
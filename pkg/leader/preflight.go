@@ -0,0 +1,178 @@
+package leader
+
+import (
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sclient "k8s.io/client-go/kubernetes"
+
+	"github.com/ghodss/yaml"
+)
+
+// Permission is a verb on a resource that an election needs.
+type Permission struct {
+	Group    string
+	Resource string
+	Verb     string
+}
+
+func (p Permission) String() string {
+	if p.Group == "" {
+		return p.Verb + " " + p.Resource
+	}
+	return p.Verb + " " + p.Resource + "." + p.Group
+}
+
+// PermissionError indicates that the service account lacks permissions the
+// election needs. Its message includes the RBAC rules that would grant them.
+type PermissionError struct {
+	Namespace string
+	Missing   []Permission
+}
+
+func (e *PermissionError) Error() string {
+	missing := make([]string, len(e.Missing))
+	for i, p := range e.Missing {
+		missing[i] = p.String()
+	}
+	msg := fmt.Sprintf("missing permissions in namespace %s: %s", e.Namespace, strings.Join(missing, ", "))
+	snippet, err := yaml.Marshal(map[string]interface{}{"rules": e.Rules()})
+	if err != nil {
+		return msg
+	}
+	return msg + "; add these rules to a Role bound to the service account:\n" + string(snippet)
+}
+
+// Rules returns RBAC policy rules that grant the missing permissions.
+func (e *PermissionError) Rules() []rbacv1.PolicyRule {
+	rules := []rbacv1.PolicyRule{}
+	index := map[Permission]int{}
+	for _, p := range e.Missing {
+		key := Permission{Group: p.Group, Resource: p.Resource}
+		i, ok := index[key]
+		if !ok {
+			i = len(rules)
+			index[key] = i
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{p.Group},
+				Resources: []string{p.Resource},
+			})
+		}
+		rules[i].Verbs = append(rules[i].Verbs, p.Verb)
+	}
+	return rules
+}
+
+// permissionError returns a *PermissionError for the provided permission if
+// err is Forbidden, and err otherwise.
+func (e *Elector) permissionError(err error, verb, resource string) error {
+	if !apierrors.IsForbidden(err) {
+		return err
+	}
+	return &PermissionError{
+		Namespace: e.ns,
+		Missing:   []Permission{{Resource: resource, Verb: verb}},
+	}
+}
+
+// rbacOptions returns the RBACOptions that describe what this Elector needs.
+func (e *Elector) rbacOptions() RBACOptions {
+	return RBACOptions{
+		Namespace:     e.ns,
+		SkipPods:      e.staticIdentity(),
+		WatchPods:     e.leaseDuration <= 0 && !e.pollPods,
+		Update:        e.lockTTL > 0 || e.leaseDuration > 0 || e.finalizer || e.detectConflicts || e.rolloutStepDown,
+		LeaderLabel:   e.leaderLabelKey != "" || e.podAnnotations,
+		StepDown:      e.safeStepDown,
+		OwnerChain:    e.rolloutStepDown,
+		ReadinessGate: e.readinessGate != "",
+		Manager:       e.shared != nil && !e.shared.polling,
+	}
+}
+
+// requiredPermissions returns every permission this Elector needs.
+func (e *Elector) requiredPermissions() []Permission {
+	perms := []Permission{}
+	for _, rule := range RBACRules(e.rbacOptions()) {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, verb := range rule.Verbs {
+					perms = append(perms, Permission{Group: group, Resource: resource, Verb: verb})
+				}
+			}
+		}
+	}
+	return perms
+}
+
+// preflight uses SelfSubjectAccessReviews to verify that this Elector has the
+// permissions it needs, and returns a *PermissionError listing those that are
+// missing. If access can't be reviewed at all, a warning is logged and nil is
+// returned, leaving any problem to surface later.
+//
+// Permission to watch pods is optional: without it, candidates poll the lock
+// every poll interval instead. So is permission to update ConfigMaps where no
+// option needs it: without it, candidates don't record themselves on the lock.
+func (e *Elector) preflight() error {
+	opts := e.rbacOptions()
+	if !opts.SkipPods && opts.WatchPods && e.shared == nil {
+		allowed, err := canI(e.client, e.ns, Permission{Resource: "pods", Verb: "watch"})
+		if err != nil {
+			e.log().Warnf("skipping permission check; failed to review access: %s", err.Error())
+			return nil
+		}
+		if !allowed {
+			e.log().Warnf("not allowed to watch pods; polling every %s instead", e.pollInterval)
+			e.pollPods = true
+		}
+	}
+	if !opts.Update {
+		allowed, err := canI(e.client, e.ns, Permission{Resource: "configmaps", Verb: "update"})
+		if err != nil {
+			e.log().Warnf("skipping permission check; failed to review access: %s", err.Error())
+			return nil
+		}
+		if !allowed {
+			e.log().Info("not allowed to update configmaps; not recording candidates on the lock")
+			e.skipRegistry = true
+		}
+	}
+
+	missing := []Permission{}
+	for _, p := range e.requiredPermissions() {
+		allowed, err := canI(e.client, e.ns, p)
+		if err != nil {
+			e.log().Warnf("skipping permission check; failed to review access: %s", err.Error())
+			return nil
+		}
+		if !allowed {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return &PermissionError{Namespace: e.ns, Missing: missing}
+	}
+	return nil
+}
+
+// canI uses a SelfSubjectAccessReview to check whether the client has the
+// provided permission in namespace ns.
+func canI(client k8sclient.Interface, ns string, p Permission) (bool, error) {
+	review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: ns,
+				Verb:      p.Verb,
+				Group:     p.Group,
+				Resource:  p.Resource,
+			},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
@@ -0,0 +1,121 @@
+package leader
+
+import (
+	"reflect"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// allow makes cluster grant only the provided permissions in access reviews.
+func allow(cluster *fakeCluster, perms ...Permission) {
+	cluster.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		asked := Permission{Group: attrs.Group, Resource: attrs.Resource, Verb: attrs.Verb}
+		for _, p := range perms {
+			if p == asked {
+				review.Status.Allowed = true
+			}
+		}
+		return true, review, nil
+	})
+}
+
+func TestPreflight(t *testing.T) {
+	base := []Permission{
+		{Resource: "configmaps", Verb: "get"},
+		{Resource: "configmaps", Verb: "create"},
+		{Resource: "configmaps", Verb: "delete"},
+		{Resource: "pods", Verb: "get"},
+	}
+	update := Permission{Resource: "configmaps", Verb: "update"}
+	watch := Permission{Resource: "pods", Verb: "watch"}
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		allowed      []Permission
+		wantMissing  []Permission
+		wantPoll     bool
+		wantRegistry bool
+	}{
+		{
+			name:         "all granted",
+			allowed:      append([]Permission{update, watch}, base...),
+			wantRegistry: true,
+		},
+		{
+			name:     "only the base permissions",
+			allowed:  base,
+			wantPoll: true,
+		},
+		{
+			name:        "create missing",
+			allowed:     []Permission{base[0], base[2], base[3]},
+			wantMissing: []Permission{base[1]},
+			wantPoll:    true,
+		},
+		{
+			name:         "update missing with a TTL",
+			opts:         []Option{WithLockTTL(testLockTTL)},
+			allowed:      append([]Permission{watch}, base...),
+			wantMissing:  []Permission{update},
+			wantRegistry: true,
+		},
+		{
+			name:         "update missing with a lease",
+			opts:         []Option{WithLease(testLeaseDuration)},
+			allowed:      base,
+			wantMissing:  []Permission{update},
+			wantRegistry: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"))
+			allow(cluster, tc.allowed...)
+			e := newTestElector("lock", cluster, clock, tc.opts...)
+			if err := e.setup(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := e.preflight()
+			var missing []Permission
+			if permErr, ok := err.(*PermissionError); ok {
+				missing = permErr.Missing
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if !reflect.DeepEqual(missing, tc.wantMissing) {
+				t.Errorf("missing %v, expected %v", missing, tc.wantMissing)
+			}
+			if e.pollPods != tc.wantPoll {
+				t.Errorf("polling is %t, expected %t", e.pollPods, tc.wantPoll)
+			}
+			if e.skipRegistry == tc.wantRegistry {
+				t.Errorf("recording candidates is %t, expected %t", !e.skipRegistry, tc.wantRegistry)
+			}
+		})
+	}
+}
+
+func TestRBACRules(t *testing.T) {
+	rules := RBACRules(RBACOptions{})
+	want := map[string][]string{
+		"configmaps": {"get", "create", "delete"},
+		"pods":       {"get"},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, expected %d: %v", len(rules), len(want), rules)
+	}
+	for _, rule := range rules {
+		if verbs := want[rule.Resources[0]]; !reflect.DeepEqual(rule.Verbs, verbs) {
+			t.Errorf("%s verbs are %v, expected %v", rule.Resources[0], rule.Verbs, verbs)
+		}
+	}
+}
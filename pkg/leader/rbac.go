@@ -0,0 +1,236 @@
+package leader
+
+import (
+	"bytes"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ghodss/yaml"
+)
+
+// DefaultRBACName is the name given to the Role and RoleBinding generated by
+// RBACManifest when no name is provided.
+const DefaultRBACName = "leader-election"
+
+// RBACOptions describes the permissions an election needs.
+type RBACOptions struct {
+	// Name is the name of the Role and RoleBinding. It defaults to
+	// DefaultRBACName.
+	Name string
+	// Namespace is the namespace in which the lock is created.
+	Namespace string
+	// ServiceAccount is the service account the election runs as. It
+	// defaults to "default".
+	ServiceAccount string
+	// SkipPods omits permission to get pods, for callers that provide their
+	// own owner with WithOwner or another StaticIdentity.
+	SkipPods bool
+	// WatchPods adds permission to watch pods, with which candidates notice
+	// that the leader's pod is gone sooner than by polling. WithLease does
+	// not use it.
+	WatchPods bool
+	// Update adds permission to update ConfigMaps, as needed by WithLockTTL,
+	// WithLease, WithFinalizer, WithIdentityConflictDetection, TransferTo and
+	// UpdateLeaderData. With it, candidates also record themselves on the
+	// lock.
+	Update bool
+	// OwnerChain adds permission to get ReplicaSets, as needed by
+	// DefaultLockName and WithRolloutStepDown.
+	OwnerChain bool
+	// LeaderLabel adds permission to list and patch pods, as needed by
+	// WithLeaderLabel and WithPodAnnotations.
+	LeaderLabel bool
+	// StepDown adds permission to list PodDisruptionBudgets, as needed by
+	// WithSafeStepDown.
+	StepDown bool
+	// Observe adds permission to list and watch ConfigMaps, as needed by
+	// Observe.
+	Observe bool
+	// Manager adds permission to list and watch ConfigMaps and pods, as
+	// needed by Manager.
+	Manager bool
+	// ReadinessGate adds permission to patch pod status, as needed by
+	// WithReadinessGate.
+	ReadinessGate bool
+	// Events adds permission to create Events.
+	Events bool
+	// Zones adds a ClusterRole with permission to get nodes, as needed by
+	// WithZonePreference.
+	Zones bool
+}
+
+// RBACRules returns the minimal policy rules needed for an election as
+// described by opts.
+func RBACRules(opts RBACOptions) []rbacv1.PolicyRule {
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get", "create", "delete"},
+		},
+	}
+	if opts.Update {
+		rules[0].Verbs = append(rules[0].Verbs, "update")
+	}
+	if opts.Observe {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"list", "watch"},
+		})
+	}
+	if opts.Manager {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps", "pods"},
+			Verbs:     []string{"list", "watch"},
+		})
+	}
+	if !opts.SkipPods {
+		verbs := []string{"get"}
+		if opts.WatchPods {
+			verbs = append(verbs, "watch")
+		}
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     verbs,
+		})
+	}
+	if opts.LeaderLabel {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     []string{"list", "patch"},
+		})
+	}
+	if opts.StepDown {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"policy"},
+			Resources: []string{"poddisruptionbudgets"},
+			Verbs:     []string{"list"},
+		})
+	}
+	if opts.ReadinessGate {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"pods/status"},
+			Verbs:     []string{"patch"},
+		})
+	}
+	if opts.OwnerChain {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"apps"},
+			Resources: []string{"replicasets"},
+			Verbs:     []string{"get"},
+		})
+	}
+	if opts.Events {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create"},
+		})
+	}
+	return rules
+}
+
+// RBACManifest returns YAML for a Role and RoleBinding that grant the service
+// account the minimal permissions needed for an election as described by opts.
+// With Zones, a ClusterRole and ClusterRoleBinding are included too.
+func RBACManifest(opts RBACOptions) ([]byte, error) {
+	if opts.Name == "" {
+		opts.Name = DefaultRBACName
+	}
+	if opts.ServiceAccount == "" {
+		opts.ServiceAccount = "default"
+	}
+
+	role := &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "Role",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Rules: RBACRules(opts),
+	}
+	binding := &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      opts.ServiceAccount,
+				Namespace: opts.Namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     opts.Name,
+		},
+	}
+
+	objs := []interface{}{role, binding}
+
+	if opts.Zones {
+		// nodes are cluster-scoped, so include the namespace in the name to
+		// keep elections in different namespaces apart
+		clusterName := opts.Namespace + "-" + opts.Name + "-nodes"
+		clusterRole := &rbacv1.ClusterRole{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "ClusterRole",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: clusterName,
+			},
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{""},
+					Resources: []string{"nodes"},
+					Verbs:     []string{"get"},
+				},
+			},
+		}
+		clusterBinding := &rbacv1.ClusterRoleBinding{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "ClusterRoleBinding",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: clusterName,
+			},
+			Subjects: binding.Subjects,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     clusterName,
+			},
+		}
+		objs = append(objs, clusterRole, clusterBinding)
+	}
+
+	var buf bytes.Buffer
+	for i, obj := range objs {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(out)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,28 @@
+package leader
+
+import (
+	"net/http"
+)
+
+// Acquired returns a channel that is closed the first time this Elector
+// becomes the leader. It stays closed even if leadership is later lost, which
+// makes it suitable for ordering startup; use Gate to follow leadership as it
+// changes.
+func (e *Elector) Acquired() <-chan struct{} {
+	return e.acquired
+}
+
+// ReadinessHandler returns an http.Handler for use as a readiness probe. It
+// responds 200 while this Elector is the leader and 503 otherwise, so that a
+// Service routes traffic only to the leader of an active/standby deployment.
+func (e *Elector) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e.gate.Leading() {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("leader\n"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not the leader\n"))
+	})
+}
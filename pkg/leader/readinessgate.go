@@ -0,0 +1,58 @@
+package leader
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/ghodss/yaml"
+)
+
+// DefaultReadinessGate is a pod condition type suitable for WithReadinessGate.
+const DefaultReadinessGate = "leader.mhrivnak.github.io/leader"
+
+// ReadinessGateSnippet returns the YAML to add to a pod spec, such as a
+// Deployment's pod template, so that the pod is ready only while the condition
+// set by WithReadinessGate is true.
+func ReadinessGateSnippet(conditionType string) ([]byte, error) {
+	return yaml.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"readinessGates": []corev1.PodReadinessGate{
+				{ConditionType: corev1.PodConditionType(conditionType)},
+			},
+		},
+	})
+}
+
+// setReadinessCondition sets the condition configured with WithReadinessGate
+// on the current pod's status. Errors are logged and otherwise ignored.
+func (e *Elector) setReadinessCondition(leading bool) {
+	if e.podName == "" {
+		return
+	}
+	status := corev1.ConditionFalse
+	if leading {
+		status = corev1.ConditionTrue
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.PodCondition{
+				{
+					Type:               corev1.PodConditionType(e.readinessGate),
+					Status:             status,
+					LastTransitionTime: metav1.NewTime(e.clock.Now()),
+					Reason:             "LeaderElection",
+				},
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+	_, err = e.client.CoreV1().Pods(e.ns).Patch(e.podName, types.StrategicMergePatchType, patch, "status")
+	if err != nil {
+		e.log().Warnf("failed to set readiness condition on pod %s: %s", e.podName, err.Error())
+	}
+}
@@ -0,0 +1,165 @@
+package leader
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// FinalizerName is the finalizer placed on the lock when WithFinalizer is
+// used.
+const FinalizerName = "leader.mhrivnak.github.io/cleanup"
+
+// ErrNotLeader indicates that an operation requiring leadership was attempted
+// by an Elector that is not the leader.
+var ErrNotLeader = errors.New("not the leader")
+
+// Release voluntarily gives up leadership. The registered cleanup hooks are run
+// first, and the lock is deleted only once they have all succeeded. If a hook
+// fails, its error is returned and the Elector remains the leader; Release may
+// be called again to retry.
+//
+// With WithFinalizer, the lock is marked for deletion before the hooks run, so
+// that it goes away even if this process exits during cleanup, once its owner
+// is gone. If a hook fails, the lock stays marked for deletion, which can't be
+// undone, but this Elector still holds it and remains the leader until a retry
+// of Release succeeds; no other pod can acquire it meanwhile. With
+// WithSafeStepDown, ErrNoStandby is returned if no healthy standby becomes
+// available in time.
+func (e *Elector) Release(ctx context.Context) error {
+	if e.Status().State != StateLeader {
+		return ErrNotLeader
+	}
+	if err := e.awaitStandby(ctx, ""); err != nil {
+		return err
+	}
+	return e.release(ctx)
+}
+
+func (e *Elector) release(ctx context.Context) error {
+	e.log().Info("releasing leadership")
+
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+
+	if e.finalizer {
+		err := cms.Delete(e.name, metav1.NewPreconditionDeleteOptions(string(e.lockUID)))
+		if err != nil && !apierrors.IsNotFound(err) {
+			e.log().Error("failed to delete lock")
+			return e.permissionError(err, "delete", "configmaps")
+		}
+		e.mu.Lock()
+		e.releasing = true
+		e.mu.Unlock()
+	}
+
+	for _, hook := range e.cleanupHooks {
+		if err := hook(ctx); err != nil {
+			e.log().Error("cleanup hook failed; not releasing the lock")
+			e.setError(err)
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	e.yielding = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.yielding = false
+		e.mu.Unlock()
+	}()
+	var err error
+	if e.finalizer {
+		err = e.removeFinalizer(e.lockUID)
+	} else {
+		err = cms.Delete(e.name, metav1.NewPreconditionDeleteOptions(string(e.lockUID)))
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		e.log().Error("failed to release lock")
+		if e.finalizer {
+			return e.permissionError(err, "update", "configmaps")
+		}
+		return e.permissionError(err, "delete", "configmaps")
+	}
+
+	e.audit(AuditReleased, e.Status().Holder, nil)
+	e.mu.Lock()
+	e.releasing = false
+	e.mu.Unlock()
+	e.setCandidate()
+	e.releaseLegacy(ctx)
+	e.releaseChildren(ctx)
+	e.log().Info("Released leadership.")
+	return nil
+}
+
+// releasingLock returns true if Release has marked the lock for deletion and
+// not yet finished.
+func (e *Elector) releasingLock() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.releasing
+}
+
+// removeFinalizer removes FinalizerName from the lock, if it has the provided
+// UID.
+func (e *Elector) removeFinalizer(uid types.UID) error {
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := cms.Get(e.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if cm.UID != uid {
+			return nil
+		}
+		return dropFinalizer(cms.Update, cm)
+	})
+}
+
+// clearStaleFinalizer removes FinalizerName from a lock that is being deleted
+// after its owner has gone away. Without this, a leader that exits without
+// calling Release would leave its lock stuck in deletion forever. Errors are
+// logged and otherwise ignored, since the caller is going to try again.
+func (e *Elector) clearStaleFinalizer(cm *corev1.ConfigMap) {
+	if cm.DeletionTimestamp == nil || !hasFinalizer(cm) {
+		return
+	}
+	for _, existingOwner := range cm.GetOwnerReferences() {
+		_, err := e.client.CoreV1().Pods(e.ns).Get(existingOwner.Name, metav1.GetOptions{})
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+	}
+	e.log().Info("Removing finalizer from a lock whose owner no longer exists.")
+	if err := dropFinalizer(e.client.CoreV1().ConfigMaps(e.ns).Update, cm); err != nil {
+		e.log().Warnf("failed to remove stale finalizer: %s", err.Error())
+	}
+}
+
+func hasFinalizer(cm *corev1.ConfigMap) bool {
+	for _, f := range cm.Finalizers {
+		if f == FinalizerName {
+			return true
+		}
+	}
+	return false
+}
+
+func dropFinalizer(update func(*corev1.ConfigMap) (*corev1.ConfigMap, error), cm *corev1.ConfigMap) error {
+	finalizers := []string{}
+	for _, f := range cm.Finalizers {
+		if f != FinalizerName {
+			finalizers = append(finalizers, f)
+		}
+	}
+	cm = cm.DeepCopy()
+	cm.Finalizers = finalizers
+	_, err := update(cm)
+	return err
+}
@@ -0,0 +1,76 @@
+package leader
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Report summarizes an Elector's election, for postmortems and for tests that
+// assert on election behavior.
+type Report struct {
+	// Lock is the name of the lock.
+	Lock string `json:"lock"`
+	// LeaderFor is the total time this Elector has been the leader,
+	// including any current term.
+	LeaderFor time.Duration `json:"leaderFor"`
+	// Transitions counts the times leadership was acquired or ended.
+	Transitions int `json:"transitions"`
+	// Takeovers counts the locks and leases this Elector removed or took
+	// over from a leader that had gone away.
+	Takeovers int `json:"takeovers"`
+	// Errors counts the errors encountered, most of them from the API.
+	Errors int `json:"errors"`
+	// FinalState and FinalHolder describe the election as last observed.
+	FinalState  State  `json:"finalState"`
+	FinalHolder string `json:"finalHolder,omitempty"`
+}
+
+// Report returns a summary of this Elector's election so far. It is safe to
+// call from any goroutine.
+func (e *Elector) Report() Report {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r := Report{
+		Lock:        e.name,
+		LeaderFor:   e.leaderFor,
+		Transitions: e.transitions,
+		Takeovers:   e.takeovers,
+		Errors:      e.errorCount,
+		FinalState:  e.state,
+		FinalHolder: e.holder,
+	}
+	if e.state == StateLeader {
+		r.LeaderFor += e.since(e.acquiredAt)
+	}
+	return r
+}
+
+// tookOver records a takeover from the provided holder.
+func (e *Elector) tookOver(holder string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.takeovers++
+	e.auditLocked(AuditTakeover, holder, nil)
+}
+
+// stopped logs the Report once Become, Run, BecomeAndRun or a Runner is about
+// to return, and passes it to the OnStop hook. The hook goroutine is then
+// stopped, once the hooks still queued have returned; goroutines Become leaves
+// maintaining the lock start it again if they call hooks.
+func (e *Elector) stopped() {
+	r := e.Report()
+	e.log().WithFields(logrus.Fields{
+		"leaderFor":   r.LeaderFor.String(),
+		"transitions": r.Transitions,
+		"takeovers":   r.Takeovers,
+		"errors":      r.Errors,
+		"finalState":  r.FinalState,
+		"finalHolder": r.FinalHolder,
+	}).Info("election stopped")
+	if f := e.hooks.OnStop; f != nil {
+		// wait, so that the hook runs before the process can exit
+		e.hookRunner.runAndWait(func() { f(e.name, r) })
+	}
+	e.hookRunner.stop()
+}
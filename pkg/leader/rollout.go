@@ -0,0 +1,81 @@
+package leader
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// revisionAnnotation is set by the Deployment controller on each ReplicaSet,
+// with the revision of the Deployment it runs.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// podRevision returns the UID of the Deployment that manages the pod, and the
+// revision of the pod's ReplicaSet. It returns false if the pod is not managed
+// by a Deployment.
+func (e *Elector) podRevision(pod *corev1.Pod) (types.UID, int, bool) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil || ref.Kind != "ReplicaSet" {
+		return "", 0, false
+	}
+	rs, err := e.client.AppsV1().ReplicaSets(e.ns).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		e.log().Debugf("failed to get ReplicaSet %s: %s", ref.Name, err.Error())
+		return "", 0, false
+	}
+	deployment := metav1.GetControllerOf(rs)
+	if deployment == nil || deployment.Kind != "Deployment" {
+		return "", 0, false
+	}
+	revision, err := strconv.Atoi(rs.Annotations[revisionAnnotation])
+	if err != nil {
+		return "", 0, false
+	}
+	return deployment.UID, revision, true
+}
+
+// noRevision is recorded as this pod's revision once it is found not to be
+// managed by a Deployment, which turns the rollout check off.
+const noRevision = -1
+
+// newerCandidate returns the identity of a ready candidate recorded on the
+// lock whose pod runs a newer revision of this pod's Deployment, or "" if
+// there is none. This pod's revision is looked up once.
+func (e *Elector) newerCandidate(cm *corev1.ConfigMap) string {
+	if e.revision == noRevision {
+		return ""
+	}
+	if e.revision == 0 {
+		if e.podName == "" {
+			e.revision = noRevision
+			return ""
+		}
+		pod, err := e.client.CoreV1().Pods(e.ns).Get(e.podName, metav1.GetOptions{})
+		if err != nil {
+			// try again at the next check
+			return ""
+		}
+		deployment, revision, ok := e.podRevision(pod)
+		if !ok {
+			e.log().Info("pod is not managed by a Deployment; not stepping down for rollouts")
+			e.revision = noRevision
+			return ""
+		}
+		e.deploymentUID, e.revision = deployment, revision
+	}
+
+	best, bestRevision := "", e.revision
+	for _, c := range liveCandidates(cm, e.clock.Now()) {
+		pod, err := e.client.CoreV1().Pods(e.ns).Get(c.Identity, metav1.GetOptions{})
+		if err != nil || pod.DeletionTimestamp != nil || !podReady(pod) {
+			continue
+		}
+		deployment, revision, ok := e.podRevision(pod)
+		if ok && deployment == e.deploymentUID && revision > bestRevision {
+			best, bestRevision = c.Identity, revision
+		}
+	}
+	return best
+}
@@ -0,0 +1,298 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// lockCheckInterval is how often the leader checks that its lock still exists.
+const lockCheckInterval = time.Second * 5
+
+// ErrLeadershipLost indicates that the lock held by this Elector was deleted
+// or replaced.
+var ErrLeadershipLost = errors.New("leadership lost")
+
+// Run campaigns for leadership of the named lock and calls fn once it is
+// acquired. The context passed to fn is cancelled if leadership is lost, in
+// which case Run waits for fn to return and then campaigns again, calling fn
+// anew each time leadership is regained.
+//
+// Run returns when ctx is done, when fn returns while still the leader, or
+// when an error prevents campaigning. fn can read what the previous leader
+// left behind with HandoffFromContext.
+func Run(ctx context.Context, name string, fn func(ctx context.Context), opts ...Option) error {
+	e := NewElector(name, opts...)
+	defer e.stopped()
+	return e.run(ctx, fn)
+}
+
+// Run campaigns for leadership and then maintains it until ctx is done,
+// campaigning again whenever leadership is lost. Use Status, WithHooks or
+// WithReadinessGate to follow the state of the election. Run suits
+// structured-concurrency code such as an errgroup.Group: it returns nil once
+// ctx is done, and an error only if one prevents campaigning. With
+// WithReleaseOnReturn, the lock is released before Run returns.
+func (e *Elector) Run(ctx context.Context) error {
+	defer e.stopped()
+	err := e.run(ctx, func(ctx context.Context) { <-ctx.Done() })
+	if ctx.Err() == nil {
+		return err
+	}
+	if e.releaseOnReturn && e.Status().State == StateLeader {
+		// ctx is done, but releasing is still worthwhile
+		if releaseErr := e.Release(context.Background()); releaseErr != nil {
+			e.log().Warnf("failed to release lock: %s", releaseErr.Error())
+		}
+	}
+	return nil
+}
+
+func (e *Elector) run(ctx context.Context, fn func(ctx context.Context)) error {
+	defer e.signalCleanup.Wait()
+	for {
+		if err := e.campaign(ctx); err != nil {
+			return err
+		}
+
+		leaderCtx, cancel := context.WithCancel(e.handoffContext(ctx))
+		lost := make(chan error, 1)
+		go func() {
+			lost <- e.maintain(leaderCtx)
+		}()
+
+		done := make(chan struct{})
+		e.leaderWork.Add(1)
+		go func() {
+			defer close(done)
+			defer e.leaderWork.Done()
+			fn(leaderCtx)
+		}()
+
+		select {
+		case <-done:
+			cancel()
+			<-lost
+			e.releaseCancelled(ctx)
+			return nil
+		case err := <-lost:
+			cancel()
+			<-done
+			if ctx.Err() != nil {
+				// maintain saw ctx done before fn returned
+				e.releaseCancelled(ctx)
+				return err
+			}
+			if err != ErrLeadershipLost {
+				return err
+			}
+			e.log().Warn("Leadership was lost; campaigning again.")
+		}
+	}
+}
+
+// BecomeAndRun becomes the leader of the named lock and then runs fn. The
+// context passed to fn is cancelled if leadership is lost or ctx is done. If
+// leadership is lost, ErrLeadershipLost is returned once fn returns, or an
+// *IdentityConflictError if another process holds the lock under the same
+// identity; otherwise fn's error is returned. With WithReleaseOnReturn, the lock is released once
+// fn returns. fn can read what the previous leader left behind with
+// HandoffFromContext.
+func BecomeAndRun(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...Option) error {
+	return NewElector(name, opts...).BecomeAndRun(ctx, fn)
+}
+
+// BecomeAndRun behaves like the package-level BecomeAndRun, using this
+// Elector.
+func (e *Elector) BecomeAndRun(ctx context.Context, fn func(ctx context.Context) error) error {
+	defer e.stopped()
+	defer e.signalCleanup.Wait()
+	if err := e.campaign(ctx); err != nil {
+		return err
+	}
+
+	leaderCtx, cancel := context.WithCancel(e.handoffContext(ctx))
+	defer cancel()
+	lost := make(chan error, 1)
+	go func() {
+		err := e.maintain(leaderCtx)
+		// fn stops once leadership has ended
+		cancel()
+		lost <- err
+	}()
+
+	e.leaderWork.Add(1)
+	err := fn(leaderCtx)
+	e.leaderWork.Done()
+	cancel()
+	if lostErr := <-lost; lostErr != leaderCtx.Err() {
+		// leadership was lost, or found to be shared with another process
+		return lostErr
+	}
+	e.releaseCancelled(ctx)
+
+	if e.releaseOnReturn && e.Status().State == StateLeader {
+		// ctx may already be done, but releasing is still worthwhile
+		if releaseErr := e.Release(context.Background()); releaseErr != nil {
+			e.log().Warnf("failed to release lock: %s", releaseErr.Error())
+		}
+	}
+	return err
+}
+
+// maintain blocks while this Elector holds its lock. It returns
+// ErrLeadershipLost once the lock is gone or leadership is given up, an
+// *IdentityConflictError if another process holds it under the same identity,
+// or ctx.Err() when ctx is done.
+func (e *Elector) maintain(ctx context.Context) error {
+	return e.maintainTerm(ctx, e.currentTerm())
+}
+
+// currentTerm returns the channel that is closed when the current term of
+// leadership ends.
+func (e *Elector) currentTerm() chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.term
+}
+
+// termEnded returns true if the term has ended.
+func termEnded(term chan struct{}) bool {
+	select {
+	case <-term:
+		return true
+	default:
+		return false
+	}
+}
+
+// maintainTerm is maintain for the provided term. Once that term has ended it
+// returns, even if this Elector has since become the leader again, so that a
+// later term is only ever maintained, or found lost, by its own caller.
+func (e *Elector) maintainTerm(ctx context.Context, term chan struct{}) error {
+	// when the API started failing, or zero if it is reachable
+	var failingSince time.Time
+	// when a candidate under this identity was last seen on the lock, as of
+	// the first check
+	var registered *time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-term:
+			// given up, as with Release
+			return ErrLeadershipLost
+		case <-e.sleeper.After(e.checkInterval()):
+		}
+
+		cm, err := e.getLock()
+		switch {
+		case err == nil && cm.UID == e.lockUID && (cm.DeletionTimestamp == nil || e.releasingLock()):
+			failingSince = time.Time{}
+			if e.detectConflicts {
+				if registered == nil {
+					seen := e.candidateSeen(cm)
+					registered = &seen
+				}
+				if e.otherInstance(cm) || e.registeredSince(cm, *registered) {
+					err := e.identityConflict()
+					e.setError(err)
+					e.lose(term)
+					return err
+				}
+			}
+			standbysGauge.WithLabelValues(e.name).Set(float64(len(liveCandidates(cm, e.clock.Now()))))
+			e.observeLock(cm)
+			if e.leaseDuration > 0 && !e.renewLease(cm) {
+				e.log().Warn("Lease was lost.")
+				return e.lose(term)
+			}
+			switch {
+			case e.lockTTL > 0:
+				if err := e.refreshExpiry(cm); err != nil {
+					e.log().Warnf("failed to refresh lock expiry: %s", err.Error())
+					e.setError(err)
+				}
+				if e.ttlExpired() {
+					e.log().Warn("Lock could not be refreshed in time; giving up leadership.")
+					return e.lose(term)
+				}
+			case e.leaseDuration <= 0 && e.detectConflicts:
+				e.heartbeat(cm)
+			}
+			if e.legacyName != "" {
+				e.retireLegacy(cm)
+			}
+			if e.rolloutStepDown {
+				if successor := e.newerCandidate(cm); successor != "" {
+					e.log().Infof("Candidate %s runs a newer revision; stepping down.", successor)
+					if err := e.TransferTo(ctx, successor); err != nil {
+						e.log().Warnf("failed to step down: %s", err.Error())
+						continue
+					}
+					return ErrLeadershipLost
+				}
+			}
+			continue
+		case err == nil, apierrors.IsNotFound(err):
+			e.log().Warn("Lock is gone.")
+			return e.lose(term)
+		default:
+			e.log().Warnf("failed to check lock: %s", err.Error())
+			e.setError(err)
+			if failingSince.IsZero() {
+				failingSince = e.clock.Now()
+			}
+			if e.leaseExpired() {
+				e.log().Warn("Lease could not be renewed in time; giving up leadership.")
+				return e.lose(term)
+			}
+			if e.ttlExpired() {
+				e.log().Warn("Lock could not be refreshed in time; giving up leadership.")
+				return e.lose(term)
+			}
+			if e.outagePolicy == FailClosed && e.since(failingSince) >= e.outageTolerance {
+				e.log().Warnf("Lock could not be checked for %s; giving up leadership.", e.outageTolerance)
+				return e.lose(term)
+			}
+		}
+	}
+}
+
+// lose applies the loss policy and then ends the term of leadership that was
+// found to be lost. It returns ErrLeadershipLost. Leadership that was given up
+// with Release, or is being, is not lost, and is left alone, as is a later
+// term than the one found lost.
+func (e *Elector) lose(term chan struct{}) error {
+	e.mu.Lock()
+	released := e.state != StateLeader || e.yielding || e.term != term
+	e.mu.Unlock()
+	if released {
+		return ErrLeadershipLost
+	}
+	if e.lossPolicy != nil {
+		e.lossPolicy(e.name)
+	}
+	e.audit(AuditLost, e.Status().Holder, nil)
+	e.setCandidate()
+	e.releaseLegacy(context.Background())
+	e.releaseChildren(context.Background())
+	return ErrLeadershipLost
+}
+
+// releaseCancelled releases the lock with WithReleaseOnCancel, if ctx was
+// cancelled while this Elector is the leader. It gives up after
+// signalCleanupTimeout.
+func (e *Elector) releaseCancelled(ctx context.Context) {
+	if !e.releaseOnCancel || ctx.Err() == nil || e.Status().State != StateLeader {
+		return
+	}
+	releaseCtx, cancel := context.WithTimeout(context.Background(), signalCleanupTimeout)
+	defer cancel()
+	if err := e.Release(releaseCtx); err != nil {
+		e.log().Warnf("failed to release lock after cancellation: %s", err.Error())
+	}
+}
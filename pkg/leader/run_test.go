@@ -0,0 +1,182 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReleaseOnCancel(t *testing.T) {
+	runs := []struct {
+		name string
+		// run campaigns and blocks until ctx is done
+		run func(ctx context.Context, e *Elector) error
+	}{
+		{
+			name: "Become",
+			run: func(ctx context.Context, e *Elector) error {
+				if err := e.Become(ctx); err != nil {
+					return err
+				}
+				<-ctx.Done()
+				return nil
+			},
+		},
+		{
+			name: "Run",
+			run: func(ctx context.Context, e *Elector) error {
+				return e.Run(ctx)
+			},
+		},
+		{
+			name: "BecomeAndRun",
+			run: func(ctx context.Context, e *Elector) error {
+				return e.BecomeAndRun(ctx, func(ctx context.Context) error {
+					<-ctx.Done()
+					return nil
+				})
+			},
+		},
+	}
+
+	for _, r := range runs {
+		for _, release := range []bool{true, false} {
+			var opts []Option
+			name := r.name
+			if release {
+				opts = append(opts, WithReleaseOnCancel())
+				name += " with WithReleaseOnCancel"
+			}
+			t.Run(name, func(t *testing.T) {
+				clock := newFakeClock()
+				cluster := newFakeCluster(clock, testPod("pod-a"))
+				e := newTestElector("lock", cluster, clock, opts...)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				result := async(func() error { return r.run(ctx, e) })
+				eventually(t, clock, 0, func() bool {
+					return e.Status().State == StateLeader
+				})
+				cancel()
+				if err := await(t, clock, 0, result); err != nil {
+					t.Fatalf("unexpected error: %s", err.Error())
+				}
+
+				if !release {
+					// a release would follow cancellation right away
+					time.Sleep(time.Millisecond * 50)
+					if cluster.lock(t, "lock") == nil {
+						t.Error("lock was released")
+					}
+					if state := e.Status().State; state != StateLeader {
+						t.Errorf("state is %s, expected leader", state)
+					}
+					return
+				}
+				eventually(t, clock, 0, func() bool {
+					return cluster.lock(t, "lock") == nil
+				})
+				eventually(t, clock, 0, func() bool {
+					return e.Status().State == StateCandidate
+				})
+			})
+		}
+	}
+}
+
+func TestLossPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		// end ends leadership held by e
+		end      func(t *testing.T, cluster *fakeCluster, e *Elector)
+		wantLost bool
+	}{
+		{
+			name: "lock deleted",
+			end: func(t *testing.T, cluster *fakeCluster, e *Elector) {
+				if err := cluster.tracker.Delete(configMapsResource, testNamespace, "lock"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantLost: true,
+		},
+		{
+			name: "released",
+			end: func(t *testing.T, cluster *fakeCluster, e *Elector) {
+				if err := e.Release(context.Background()); err != nil {
+					t.Fatalf("Release failed: %s", err.Error())
+				}
+			},
+		},
+		{
+			// as when a Group gives a lock back and acquires it again
+			name: "earlier term found lost",
+			end: func(t *testing.T, cluster *fakeCluster, e *Elector) {
+				earlier := e.currentTerm()
+				if err := e.Release(context.Background()); err != nil {
+					t.Fatalf("Release failed: %s", err.Error())
+				}
+				if err := e.Become(context.Background()); err != nil {
+					t.Fatalf("Become failed: %s", err.Error())
+				}
+				e.lose(earlier)
+				if state := e.Status().State; state != StateLeader {
+					t.Errorf("state is %s, expected leader", state)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, testPod("pod-a"))
+			lost := make(chan State, 1)
+			var e *Elector
+			e = newTestElector("lock", cluster, clock, WithLockTTL(testLockTTL), WithLossPolicy(func(lock string) {
+				// the policy runs before leadership is given up
+				lost <- e.Status().State
+			}))
+			if err := await(t, clock, 0, async(func() error { return e.Become(context.Background()) })); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+
+			tc.end(t, cluster, e)
+			if !tc.wantLost {
+				never(t, clock, e.checkInterval(), nil)
+				if len(lost) != 0 {
+					t.Error("loss policy was applied")
+				}
+				return
+			}
+			eventually(t, clock, e.checkInterval(), func() bool { return len(lost) != 0 })
+			if state := <-lost; state != StateLeader {
+				t.Errorf("state was %s when the loss policy was applied, expected leader", state)
+			}
+			eventually(t, clock, 0, func() bool { return e.Status().State == StateCandidate })
+		})
+	}
+}
+
+func TestBecomeAndRunIdentityConflict(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	e := newTestElector("lock", cluster, clock, WithIdentityConflictDetection())
+
+	result := async(func() error {
+		return e.BecomeAndRun(context.Background(), func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+	})
+	eventually(t, clock, 0, func() bool { return e.Status().State == StateLeader })
+	cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+		cm.Annotations[instanceAnnotation] = "other"
+	})
+	err := await(t, clock, lockCheckInterval, result)
+	if conflict, ok := err.(*IdentityConflictError); !ok || conflict.Identity != "pod-a" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package leader
+
+import (
+	"context"
+	"sync"
+)
+
+// Task is a long-running function run by a Runner. It should return once its
+// context is done.
+type Task func(ctx context.Context) error
+
+// Runner runs two sets of tasks for an election: tasks that always run, such as
+// metrics servers and webhooks, and tasks that run only while leading, such as
+// controllers. Leader-only tasks are started each time leadership is acquired
+// and cancelled when it is lost.
+//
+// Shutdown is ordered: once the Runner's context is done, leader-only tasks are
+// cancelled and waited for, then the lock is released with
+// WithReleaseOnReturn, and then always-on tasks are cancelled and waited for.
+type Runner struct {
+	elector    *Elector
+	always     []Task
+	leaderOnly []Task
+}
+
+// NewRunner returns a Runner for the election of e.
+func NewRunner(e *Elector) *Runner {
+	return &Runner{elector: e}
+}
+
+// Always adds tasks that run regardless of leadership.
+func (r *Runner) Always(tasks ...Task) {
+	r.always = append(r.always, tasks...)
+}
+
+// LeaderOnly adds tasks that run only while leading.
+func (r *Runner) LeaderOnly(tasks ...Task) {
+	r.leaderOnly = append(r.leaderOnly, tasks...)
+}
+
+// Run starts the always-on tasks, campaigns for leadership, and runs the
+// leader-only tasks while leading. It returns nil once ctx is done and every
+// task has returned. If a task returns an error while its context is live, or
+// campaigning fails, everything is shut down as described on Runner and that
+// error is returned.
+func (r *Runner) Run(ctx context.Context) error {
+	defer r.elector.stopped()
+	// always-on tasks outlive ctx until the election has ended
+	alwaysCtx, cancelAlways := context.WithCancel(context.Background())
+	defer cancelAlways()
+	electionCtx, cancelElection := context.WithCancel(ctx)
+	defer cancelElection()
+
+	alwaysErr := make(chan error, 1)
+	var always sync.WaitGroup
+	for _, task := range r.always {
+		always.Add(1)
+		go func(task Task) {
+			defer always.Done()
+			if err := task(alwaysCtx); err != nil && alwaysCtx.Err() == nil {
+				select {
+				case alwaysErr <- err:
+				default:
+				}
+			}
+		}(task)
+	}
+
+	var leaderMu sync.Mutex
+	var leaderErr error
+	done := make(chan error, 1)
+	go func() {
+		done <- r.elector.run(electionCtx, func(leaderCtx context.Context) {
+			if err := runTasks(leaderCtx, r.leaderOnly); err != nil {
+				leaderMu.Lock()
+				leaderErr = err
+				leaderMu.Unlock()
+				cancelElection()
+				return
+			}
+			// keep leading once the tasks are finished
+			<-leaderCtx.Done()
+		})
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case err = <-alwaysErr:
+		cancelElection()
+		<-done
+	}
+	leaderMu.Lock()
+	if leaderErr != nil {
+		err = leaderErr
+	}
+	leaderMu.Unlock()
+	if err == ctx.Err() {
+		err = nil
+	}
+
+	if r.elector.releaseOnReturn && r.elector.Status().State == StateLeader {
+		if releaseErr := r.elector.Release(context.Background()); releaseErr != nil {
+			r.elector.log().Warnf("failed to release lock: %s", releaseErr.Error())
+		}
+	}
+
+	cancelAlways()
+	always.Wait()
+	return err
+}
+
+// runTasks runs tasks until they have all returned. The first error returned
+// while ctx is live cancels the others and is returned.
+func runTasks(ctx context.Context, tasks []Task) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var first error
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task Task) {
+			defer wg.Done()
+			if err := task(ctx); err != nil && ctx.Err() == nil {
+				once.Do(func() {
+					first = err
+					cancel()
+				})
+			}
+		}(task)
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,156 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// slotAttemptTimeout is how long a Semaphore tries one slot before moving on to
+// the next.
+const slotAttemptTimeout = time.Second * 1
+
+// ErrNoSlot indicates that a Semaphore does not hold a slot.
+var ErrNoSlot = errors.New("no semaphore slot held")
+
+// Semaphore allows up to a fixed number of pods to hold it at once, for work
+// that should run on at most that many pods. Each slot is a lock of its own,
+// named "<name>-<index>", with the same for-life semantics as any other lock: a
+// slot is held until its pod goes away or releases it. A pod holds at most one
+// slot of a Semaphore.
+type Semaphore struct {
+	electors []*Elector
+
+	mu   sync.Mutex
+	held int
+}
+
+// NewSemaphore returns a Semaphore with the provided number of slots. The
+// options apply to the Elector of every slot.
+func NewSemaphore(name string, slots int, opts ...Option) *Semaphore {
+	s := &Semaphore{held: -1}
+	for i := 0; i < slots; i++ {
+		s.electors = append(s.electors, NewElector(fmt.Sprintf("%s-%d", name, i), opts...))
+	}
+	return s
+}
+
+// Acquire blocks until this pod holds a slot, or ctx is done, and returns the
+// index of the slot. Slots are tried in turn, starting from a random one so
+// that candidates spread out. A slot already held by this pod, for example
+// before a restart, is resumed right away.
+func (s *Semaphore) Acquire(ctx context.Context) (int, error) {
+	if slot := s.Slot(); slot >= 0 {
+		return slot, nil
+	}
+	if len(s.electors) == 0 {
+		return -1, ErrNoSlot
+	}
+
+	next, err := s.heldSlot()
+	if err != nil {
+		return -1, err
+	}
+	resume := next >= 0
+	if !resume {
+		next = rand.Intn(len(s.electors))
+	}
+	for {
+		e := s.electors[next]
+		timeout := slotAttemptTimeout
+		if resume {
+			// resuming may wait for a heartbeat from another process
+			timeout = heartbeatTimeout + slotAttemptTimeout
+			resume = false
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := e.becomeUntil(attemptCtx, ctx)
+		cancel()
+		switch {
+		case err == nil:
+			s.mu.Lock()
+			s.held = next
+			s.mu.Unlock()
+			e.log().Infof("Acquired semaphore slot %d.", next)
+			return next, nil
+		case ctx.Err() != nil:
+			return -1, ctx.Err()
+		case err != context.DeadlineExceeded:
+			return -1, err
+		}
+		next = (next + 1) % len(s.electors)
+	}
+}
+
+// heldSlot returns the index of a slot whose lock is already held under this
+// pod's identity, as after a restart, or -1 if there is none. Trying that slot
+// first resumes it, so that the pod does not hold a second slot.
+func (s *Semaphore) heldSlot() (int, error) {
+	first := s.electors[0]
+	if err := first.setup(); err != nil {
+		return -1, err
+	}
+	if first.identity == "" {
+		if err := first.resolveOwner(); err != nil {
+			return -1, err
+		}
+	}
+	cms := first.client.CoreV1().ConfigMaps(first.ns)
+	for i, e := range s.electors {
+		cm, err := cms.Get(e.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return -1, first.permissionError(err, "get", "configmaps")
+		}
+		if lockHolder(cm) == first.identity {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// Slot returns the index of the slot held by this pod, or -1 if it holds none.
+// Once the loss of a slot is detected, which happens with WithLockTTL or
+// WithLease, it is no longer reported.
+func (s *Semaphore) Slot() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held >= 0 && s.electors[s.held].Status().State != StateLeader {
+		s.held = -1
+	}
+	return s.held
+}
+
+// Elector returns the Elector of the slot held by this pod, or nil if it holds
+// none. It can be used to watch the slot with Gate or Acquired.
+func (s *Semaphore) Elector() *Elector {
+	slot := s.Slot()
+	if slot < 0 {
+		return nil
+	}
+	return s.electors[slot]
+}
+
+// Release releases the slot held by this pod, as with Elector.Release.
+// ErrNoSlot is returned if it holds none.
+func (s *Semaphore) Release(ctx context.Context) error {
+	e := s.Elector()
+	if e == nil {
+		return ErrNoSlot
+	}
+	if err := e.Release(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.held = -1
+	s.mu.Unlock()
+	return nil
+}
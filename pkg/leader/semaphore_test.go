@@ -0,0 +1,103 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSemaphoreAcquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		slots    int
+		objs     []runtime.Object
+		timeout  time.Duration
+		wantSlot int
+		wantErr  error
+	}{
+		{
+			name:     "free slot",
+			slots:    2,
+			objs:     []runtime.Object{testLock("jobs-0", "pod-b", nil)},
+			timeout:  testTimeout,
+			wantSlot: 1,
+		},
+		{
+			name:     "held slot is resumed",
+			slots:    3,
+			objs:     []runtime.Object{testLock("jobs-2", "pod-a", nil)},
+			timeout:  testTimeout,
+			wantSlot: 2,
+		},
+		{
+			name:  "no free slot",
+			slots: 2,
+			objs: []runtime.Object{
+				testLock("jobs-0", "pod-b", nil),
+				testLock("jobs-1", "pod-c", nil),
+			},
+			timeout:  slotAttemptTimeout / 10,
+			wantSlot: -1,
+			wantErr:  context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			objs := append(tc.objs, testPod("pod-a"), testPod("pod-b"), testPod("pod-c"))
+			cluster := newFakeCluster(clock, objs...)
+			s := NewSemaphore("jobs", tc.slots, testOptions(cluster, clock)...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			slot, err := s.Acquire(ctx)
+			if err != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if slot != tc.wantSlot || s.Slot() != tc.wantSlot {
+				t.Errorf("acquired slot %d, reported as %d, expected %d", slot, s.Slot(), tc.wantSlot)
+			}
+			held, want := 0, 1
+			if tc.wantSlot < 0 {
+				want = 0
+			}
+			for _, e := range s.electors {
+				if cm := cluster.lock(t, e.name); cm != nil && lockHolder(cm) == "pod-a" {
+					held++
+				}
+			}
+			if held != want {
+				t.Errorf("pod-a holds %d slots, expected %d", held, want)
+			}
+		})
+	}
+}
+
+func TestSemaphoreRelease(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	s := NewSemaphore("jobs", 1, testOptions(cluster, clock)...)
+
+	if err := s.Release(context.Background()); err != ErrNoSlot {
+		t.Errorf("unexpected error releasing before acquiring: %v", err)
+	}
+	slot, err := s.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %s", err.Error())
+	}
+	if again, err := s.Acquire(context.Background()); err != nil || again != slot {
+		t.Errorf("acquiring again returned %d, %v; expected %d", again, err, slot)
+	}
+	if err := s.Release(context.Background()); err != nil {
+		t.Fatalf("Release failed: %s", err.Error())
+	}
+	if cluster.lock(t, "jobs-0") != nil {
+		t.Error("slot lock still exists")
+	}
+	if s.Slot() != -1 || s.Elector() != nil {
+		t.Errorf("slot %d still reported as held", s.Slot())
+	}
+}
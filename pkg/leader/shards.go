@@ -0,0 +1,202 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// shardSyncInterval is how often a Sharder records itself as a member
+	// and reconsiders which shards it should lead.
+	shardSyncInterval = candidateRefreshInterval
+	// maxShardMembers bounds the number of members recorded for a Sharder.
+	maxShardMembers = 100
+)
+
+// Sharder divides leadership of a fixed number of shards among the live
+// processes running it. Each shard is a lock named "<name>-shard-<index>".
+// Members record themselves on a ConfigMap named "<name>-members", and each
+// shard is assigned to a member by rendezvous hashing, so that a change of
+// membership moves as few shards as possible. A member campaigns for the
+// shards assigned to it, and releases shards that are no longer assigned to
+// it.
+type Sharder struct {
+	m       *Manager
+	name    string
+	shards  int
+	members *Elector
+}
+
+// Sharder returns a Sharder for the named set of shards, whose locks are run
+// by the Manager.
+func (m *Manager) Sharder(name string, shards int) *Sharder {
+	return &Sharder{
+		m:       m,
+		name:    name,
+		shards:  shards,
+		members: m.Elector(name + "-members"),
+	}
+}
+
+// Run records this process as a member and calls fn for each shard while this
+// process leads it, until ctx is done. The context passed to fn is cancelled
+// if the shard is lost or assigned to another member, after which the shard
+// is released. fn should block until its context is done; if it returns
+// early, the shard is released and fn is called again at the next sync if the
+// shard is still assigned here.
+func (s *Sharder) Run(ctx context.Context, fn func(ctx context.Context, shard int)) error {
+	e := s.members
+	if err := e.setup(); err != nil {
+		return err
+	}
+	if e.identity == "" {
+		if err := e.resolveOwner(); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	running := map[int]*shardRun{}
+	defer func() {
+		for _, r := range running {
+			r.cancel()
+		}
+		wg.Wait()
+	}()
+
+	for {
+		for shard, r := range running {
+			select {
+			case <-r.done:
+				delete(running, shard)
+			default:
+			}
+		}
+
+		members, err := s.heartbeat()
+		if err != nil {
+			e.log().Warnf("failed to record shard membership: %s", err.Error())
+		} else {
+			for shard := 0; shard < s.shards; shard++ {
+				mine := assignShard(members, shard) == e.identity
+				r, ok := running[shard]
+				switch {
+				case mine && !ok:
+					shardCtx, cancel := context.WithCancel(ctx)
+					r = &shardRun{cancel: cancel, done: make(chan struct{})}
+					running[shard] = r
+					wg.Add(1)
+					go func(shard int) {
+						defer wg.Done()
+						defer close(r.done)
+						s.runShard(shardCtx, shard, fn)
+					}(shard)
+				case !mine && ok:
+					e.log().Infof("Shard %d is assigned to another member; releasing it.", shard)
+					r.cancel()
+				}
+			}
+		}
+
+		select {
+		case <-e.sleeper.After(shardSyncInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// shardRun tracks a shard this process campaigns for.
+type shardRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// runShard campaigns for the shard and runs fn while leading it, until ctx is
+// done, and then releases the shard.
+func (s *Sharder) runShard(ctx context.Context, shard int, fn func(ctx context.Context, shard int)) {
+	e := s.m.Elector(fmt.Sprintf("%s-shard-%d", s.name, shard))
+	err := e.run(ctx, func(ctx context.Context) { fn(ctx, shard) })
+	if err != nil && err != ctx.Err() {
+		e.log().Warnf("shard election failed: %s", err.Error())
+	}
+	if e.Status().State == StateLeader {
+		if err := e.release(context.Background()); err != nil {
+			e.log().Warnf("failed to release shard: %s", err.Error())
+		}
+	}
+}
+
+// heartbeat records this process on the members ConfigMap and returns the
+// identities of the live members, including this one.
+func (s *Sharder) heartbeat() ([]string, error) {
+	e := s.members
+	now := e.clock.Now()
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+
+	cm, err := cms.Get(e.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: e.name, Namespace: e.ns},
+		}
+		if cm, err = withCandidate(cm, e.identity, now, maxShardMembers); err != nil {
+			return nil, err
+		}
+		if cm, err = cms.Create(cm); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if cm, err = withCandidate(cm, e.identity, now, maxShardMembers); err != nil {
+			return nil, err
+		}
+		if cm, err = cms.Update(cm); err != nil {
+			return nil, err
+		}
+	}
+
+	members := []string{}
+	for _, c := range liveCandidates(cm, now) {
+		members = append(members, c.Identity)
+	}
+	return members, nil
+}
+
+// assignShard returns the member to which the shard is assigned, by rendezvous
+// hashing: the member with the highest hash of its identity and the shard.
+func assignShard(members []string, shard int) string {
+	var best string
+	var bestHash uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		h.Write([]byte(member + "/" + strconv.Itoa(shard)))
+		sum := mix64(h.Sum64())
+		if best == "" || sum > bestHash || (sum == bestHash && member < best) {
+			best = member
+			bestHash = sum
+		}
+	}
+	return best
+}
+
+// mix64 scrambles the bits of an FNV hash. Identities such as pod names often
+// differ only in their last character, and the high bits of their FNV hashes,
+// which decide the comparison in assignShard, then barely differ, so that
+// most shards go to the same member.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
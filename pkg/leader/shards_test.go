@@ -0,0 +1,139 @@
+package leader
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAssignShard(t *testing.T) {
+	members := []string{"pod-a", "pod-b", "pod-c"}
+	for shard := 0; shard < 20; shard++ {
+		owner := assignShard(members, shard)
+		if got := assignShard([]string{"pod-c", "pod-a", "pod-b"}, shard); got != owner {
+			t.Errorf("shard %d is assigned to %s or %s depending on member order", shard, owner, got)
+		}
+		// a member leaving moves only the shards it had
+		if got := assignShard(members[:2], shard); owner != "pod-c" && got != owner {
+			t.Errorf("shard %d moved from %s to %s when pod-c left", shard, owner, got)
+		}
+	}
+	// pods of a StatefulSet, whose names differ in one character, each get a
+	// fair share
+	counts := map[string]int{}
+	for shard := 0; shard < 60; shard++ {
+		counts[assignShard([]string{"app-0", "app-1", "app-2"}, shard)]++
+	}
+	for _, member := range []string{"app-0", "app-1", "app-2"} {
+		if counts[member] < 10 {
+			t.Errorf("%s is assigned %d of 60 shards, expected about 20", member, counts[member])
+		}
+	}
+	if got := assignShard(nil, 0); got != "" {
+		t.Errorf("shard is assigned to %q without members", got)
+	}
+}
+
+// shardRecorder records which shards each member is running.
+type shardRecorder struct {
+	mu      sync.Mutex
+	running map[string]map[int]bool
+}
+
+func (r *shardRecorder) fn(member string) func(ctx context.Context, shard int) {
+	return func(ctx context.Context, shard int) {
+		r.mu.Lock()
+		if r.running[member] == nil {
+			r.running[member] = map[int]bool{}
+		}
+		r.running[member][shard] = true
+		r.mu.Unlock()
+		<-ctx.Done()
+		r.mu.Lock()
+		delete(r.running[member], shard)
+		r.mu.Unlock()
+	}
+}
+
+// shards returns the shards the member is running, in order.
+func (r *shardRecorder) shards(member string) []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shards := []int{}
+	for shard := range r.running[member] {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+func TestSharder(t *testing.T) {
+	const shards = 8
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"))
+	recorder := &shardRecorder{running: map[string]map[int]bool{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	run := func(ctx context.Context, member string) <-chan error {
+		m, err := NewManager(ctx, append(testOptions(cluster, clock), WithHostname(member))...)
+		if err != nil {
+			t.Fatalf("NewManager failed: %s", err.Error())
+		}
+		return async(func() error { return m.Sharder("work", shards).Run(ctx, recorder.fn(member)) })
+	}
+	// want returns the shards assigned to member among members.
+	want := func(member string, members ...string) []int {
+		assigned := []int{}
+		for shard := 0; shard < shards; shard++ {
+			if assignShard(members, shard) == member {
+				assigned = append(assigned, shard)
+			}
+		}
+		return assigned
+	}
+	settles := func(members ...string) {
+		t.Helper()
+		eventually(t, clock, shardSyncInterval, func() bool {
+			for _, member := range []string{"pod-a", "pod-b"} {
+				if !reflect.DeepEqual(recorder.shards(member), want(member, members...)) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	// a lone member leads every shard
+	resultA := run(ctx, "pod-a")
+	settles("pod-a")
+
+	// a second member takes over the shards assigned to it
+	ctxB, cancelB := context.WithCancel(ctx)
+	resultB := run(ctxB, "pod-b")
+	settles("pod-a", "pod-b")
+	for shard := 0; shard < shards; shard++ {
+		holder := lockHolder(cluster.lock(t, "work-shard-"+strconv.Itoa(shard)))
+		if owner := assignShard([]string{"pod-a", "pod-b"}, shard); holder != owner {
+			t.Errorf("shard %d is held by %q, expected %s", shard, holder, owner)
+		}
+	}
+
+	// once the second member stops, its shards return to the first
+	cancelB()
+	if err := await(t, clock, 0, resultB); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	settles("pod-a")
+
+	cancel()
+	if err := await(t, clock, 0, resultA); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := recorder.shards("pod-a"); len(got) != 0 {
+		t.Errorf("shards %v are still running", got)
+	}
+}
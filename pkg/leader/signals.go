@@ -0,0 +1,123 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// signalCleanupTimeout bounds how long HandleSignals spends deleting the lock,
+// as does WithReleaseOnCancel.
+const signalCleanupTimeout = 5 * time.Second
+
+// HandleSignals installs handlers for SIGTERM and SIGINT and returns a context
+// that is cancelled when either is received. After the context is cancelled,
+// the named lock is deleted if it is held by the current pod, so that another
+// pod can become the leader without waiting for garbage collection. Deletion is
+// best-effort and bounded by a timeout. A second signal exits the process
+// immediately.
+//
+// The lock must outlive the work done while leading, or two leaders may run at
+// once. The package-level HandleSignals can't tell when that work has stopped,
+// so callers should have stopped it before the lock is deleted, or else use
+// the Elector's own HandleSignals.
+//
+// This covers exit paths that a preStop hook does not, such as the process
+// exiting on its own after a signal. Callers with their own signal handling can
+// use the returned context in place of it.
+func HandleSignals(name string, opts ...Option) context.Context {
+	return NewElector(name, opts...).HandleSignals()
+}
+
+// HandleSignals behaves like the package-level HandleSignals, using this
+// Elector. The lock is deleted only once the leader functions passed to this
+// Elector's Run, BecomeAndRun or Runner have returned, and those do not return
+// until it has been deleted, so the process does not exit first. If this
+// Elector is the leader, the lock is released with Release, so that its
+// cleanup hooks run.
+func (e *Elector) HandleSignals() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		e.log().Infof("received %s; stopping leader work before deleting lock", sig)
+		e.signalCleanup.Add(1)
+		defer e.signalCleanup.Done()
+		cancel()
+		go func() {
+			<-signals
+			os.Exit(1)
+		}()
+		e.leaderWork.Wait()
+		e.deleteOnSignal()
+	}()
+	return ctx
+}
+
+// deleteOnSignal deletes the lock if it is held by this process, giving up
+// after signalCleanupTimeout.
+func (e *Elector) deleteOnSignal() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		if e.Status().State == StateLeader {
+			done <- e.Release(ctx)
+			return
+		}
+		done <- e.deleteOwnedLock()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			e.log().Warnf("failed to delete lock: %s", err.Error())
+		}
+	case <-e.sleeper.After(signalCleanupTimeout):
+		e.log().Warn("timed out deleting lock")
+	}
+}
+
+// deleteOwnedLock deletes the lock if its holder is the current pod. It is used
+// when the lock was acquired by a different Elector in this process, such as
+// the one created by the package-level Become.
+func (e *Elector) deleteOwnedLock() error {
+	if err := e.setup(); err != nil {
+		return err
+	}
+	if e.identity == "" {
+		if err := e.resolveOwner(); err != nil {
+			return err
+		}
+	}
+
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	cm, err := cms.Get(e.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lockHolder(cm) != e.identity {
+		return nil
+	}
+
+	err = cms.Delete(e.name, metav1.NewPreconditionDeleteOptions(string(cm.UID)))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if hasFinalizer(cm) {
+		if err := e.removeFinalizer(cm.UID); err != nil {
+			return err
+		}
+	}
+	e.log().Info("Deleted lock.")
+	return nil
+}
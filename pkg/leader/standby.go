@@ -0,0 +1,15 @@
+package leader
+
+// checkStandby calls the function set with WithStandby once this Elector has
+// waited longer than allowed to become the leader. It is called at most once
+// per campaign; the campaign continues regardless.
+func (e *Elector) checkStandby() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.onStandby == nil || e.standbyNotified || e.waitingSince.IsZero() || e.since(e.waitingSince) < e.standbyAfter {
+		return
+	}
+	e.standbyNotified = true
+	f, holder := e.onStandby, e.holder
+	e.hookRunner.run(e.name, func() { f(holder) })
+}
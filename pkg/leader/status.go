@@ -0,0 +1,167 @@
+package leader
+
+import (
+	"time"
+)
+
+// State describes the role an Elector currently plays in its election.
+type State string
+
+const (
+	// StateCandidate means the Elector does not hold the lock.
+	StateCandidate State = "candidate"
+	// StateLeader means the Elector holds the lock.
+	StateLeader State = "leader"
+)
+
+// LeadershipStatus is a point-in-time snapshot of an Elector's view of its
+// election, suitable for status endpoints and debug dumps.
+type LeadershipStatus struct {
+	// Lock is the name of the lock.
+	Lock string `json:"lock"`
+	// Namespace is the namespace of the lock, once it has been resolved.
+	Namespace string `json:"namespace,omitempty"`
+	// State is whether this Elector is a candidate or the leader.
+	State State `json:"state"`
+	// Holder is the name of the current lock holder as last observed, which
+	// may be empty if no holder has been seen.
+	Holder string `json:"holder,omitempty"`
+	// AcquiredAt is when this Elector became the leader. It is zero unless
+	// State is StateLeader.
+	AcquiredAt time.Time `json:"acquiredAt,omitempty"`
+	// LeaderFor is the time elapsed since AcquiredAt.
+	LeaderFor time.Duration `json:"leaderFor,omitempty"`
+	// Backend is the backend chosen from those set with WithBackends, once
+	// the election has started.
+	Backend Backend `json:"backend,omitempty"`
+	// Epoch is the leadership epoch of the current term. It is zero unless
+	// State is StateLeader.
+	Epoch int64 `json:"epoch,omitempty"`
+	// Attempts is the number of times this Elector has tried to create the
+	// lock.
+	Attempts int `json:"attempts"`
+	// LastError is the most recent error encountered, if any.
+	LastError error `json:"-"`
+}
+
+// Status returns a snapshot of the Elector's current election status. It is
+// safe to call from any goroutine.
+func (e *Elector) Status() LeadershipStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := LeadershipStatus{
+		Lock:      e.name,
+		Namespace: e.ns,
+		State:     e.state,
+		Holder:    e.holder,
+		Backend:   e.backend,
+		Attempts:  e.attempts,
+		LastError: e.lastErr,
+	}
+	if e.state == StateLeader {
+		s.AcquiredAt = e.acquiredAt
+		s.LeaderFor = e.since(e.acquiredAt)
+		s.Epoch = e.epoch
+	}
+	return s
+}
+
+func (e *Elector) setLeader(holder string, at time.Time) {
+	var t transition
+	defer func() { e.hookRunner.runTransition(t) }()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state != StateLeader {
+		e.term = make(chan struct{})
+	}
+	e.state = StateLeader
+	e.transitions++
+	e.observeHolderLocked(holder)
+	e.holder = holder
+	e.waitingSince = time.Time{}
+	e.standbyNotified = false
+	waitingSecondsGauge.WithLabelValues(e.name).Set(0)
+	e.acquiredAt = at
+	e.takeHandoffLocked()
+	e.auditLocked(AuditAcquired, holder, nil)
+	e.backoff.Forget(e.name)
+	e.gate.open()
+	e.acquireOnce.Do(func() { close(e.acquired) })
+	isLeaderGauge.WithLabelValues(e.name).Set(1)
+	if e.shouldNotify(transitionAcquired, holder) {
+		acquisitionsCounter.WithLabelValues(e.name).Inc()
+		e.onAcquired(&t)
+		e.pushMetricsLocked()
+	}
+	if e.leaderLabelKey != "" {
+		t.add(func() { e.labelLeaderPod(true) })
+	}
+	if e.podAnnotations {
+		t.add(func() { e.annotateLeaderPod(true, at) })
+	}
+	if e.readinessGate != "" {
+		t.add(func() { e.setReadinessCondition(true) })
+	}
+}
+
+func (e *Elector) setCandidate() {
+	var t transition
+	defer func() { e.hookRunner.runTransition(t) }()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == StateLeader {
+		e.transitions++
+		e.leaderFor += e.since(e.acquiredAt)
+		isLeaderGauge.WithLabelValues(e.name).Set(0)
+		if e.shouldNotify(transitionLost, e.identity) {
+			lossesCounter.WithLabelValues(e.name).Inc()
+			e.onLost(&t)
+			e.pushMetricsLocked()
+		}
+		if e.leaderLabelKey != "" {
+			t.add(func() { e.labelLeaderPod(false) })
+		}
+		if e.podAnnotations {
+			t.add(func() { e.annotateLeaderPod(false, time.Time{}) })
+		}
+		if e.readinessGate != "" {
+			t.add(func() { e.setReadinessCondition(false) })
+		}
+		close(e.term)
+	}
+	e.state = StateCandidate
+	e.observeHolderLocked("")
+	e.holder = ""
+	e.acquiredAt = time.Time{}
+	e.gate.close()
+}
+
+func (e *Elector) setHolder(holder string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if holder != "" && holder != e.holder {
+		e.auditLocked(AuditHolderObserved, holder, nil)
+	}
+	e.observeHolderLocked(holder)
+	e.holder = holder
+}
+
+func (e *Elector) addAttempt() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attempts++
+	e.auditLocked(AuditAttempt, "", nil)
+	attemptsCounter.WithLabelValues(e.name).Inc()
+	e.onAttempt(e.attempts)
+}
+
+func (e *Elector) setError(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastErr = err
+	e.errorCount++
+	e.auditLocked(AuditError, "", err)
+	errorsCounter.WithLabelValues(e.name).Inc()
+	e.onError(err)
+}
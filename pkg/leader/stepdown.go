@@ -0,0 +1,111 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ErrNoStandby indicates that a voluntary step-down was refused because no
+// healthy standby was available to take over.
+var ErrNoStandby = errors.New("no healthy standby available")
+
+// standbyPollInterval is how often a step-down checks for a healthy standby
+// while waiting for one.
+const standbyPollInterval = time.Second * 2
+
+// awaitStandby blocks until a healthy standby is available, as required by
+// WithSafeStepDown, and returns ErrNoStandby if none becomes available in
+// time. If successor is not empty, only that candidate counts. Without
+// WithSafeStepDown, it returns nil right away.
+func (e *Elector) awaitStandby(ctx context.Context, successor string) error {
+	if !e.safeStepDown {
+		return nil
+	}
+	deadline := e.clock.Now().Add(e.stepDownWait)
+	for {
+		if e.standbyReady(successor) {
+			return nil
+		}
+		if !e.clock.Now().Before(deadline) {
+			e.log().Warn("No healthy standby is available; not stepping down.")
+			return ErrNoStandby
+		}
+		e.log().Info("Waiting for a healthy standby before stepping down.")
+		select {
+		case <-e.sleeper.After(standbyPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// standbyReady returns true if a candidate recorded on the lock has a ready
+// pod, and no PodDisruptionBudget covering the current pod has run out of
+// allowed disruptions.
+func (e *Elector) standbyReady(successor string) bool {
+	cm, err := e.getLock()
+	if err != nil {
+		e.log().Warnf("failed to get lock: %s", err.Error())
+		return false
+	}
+	if !e.disruptionAllowed() {
+		return false
+	}
+
+	for _, c := range liveCandidates(cm, e.clock.Now()) {
+		if successor != "" && c.Identity != successor {
+			continue
+		}
+		pod, err := e.client.CoreV1().Pods(e.ns).Get(c.Identity, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		if pod.DeletionTimestamp == nil && podReady(pod) {
+			return true
+		}
+	}
+	return false
+}
+
+// disruptionAllowed returns false if a PodDisruptionBudget selecting the
+// current pod allows no disruptions, meaning the other pods it covers are not
+// healthy enough to spare one. If budgets can't be checked, true is returned.
+func (e *Elector) disruptionAllowed() bool {
+	if e.podName == "" {
+		return true
+	}
+	pod, err := e.client.CoreV1().Pods(e.ns).Get(e.podName, metav1.GetOptions{})
+	if err != nil {
+		return true
+	}
+	pdbs, err := e.client.PolicyV1beta1().PodDisruptionBudgets(e.ns).List(metav1.ListOptions{})
+	if err != nil {
+		e.log().Debugf("skipping PodDisruptionBudget check: %s", err.Error())
+		return true
+	}
+	for _, pdb := range pdbs.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if pdb.Status.PodDisruptionsAllowed == 0 {
+			e.log().Infof("PodDisruptionBudget %s allows no disruptions.", pdb.Name)
+			return false
+		}
+	}
+	return true
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testStepDownWait = time.Second * 10
+
+// readyPod returns a running pod with the provided name that is ready.
+func readyPod(name string) *corev1.Pod {
+	pod := testPod(name)
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	return pod
+}
+
+func TestSafeStepDown(t *testing.T) {
+	leader := testPod("pod-a")
+	leader.Labels = map[string]string{"app": "test"}
+	budget := func(allowed int32) *policyv1beta1.PodDisruptionBudget {
+		return &policyv1beta1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "budget", Namespace: testNamespace},
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+			},
+			Status: policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: allowed},
+		}
+	}
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		// standby, if set, records pod-b as a candidate on the lock
+		standby bool
+		wantErr error
+	}{
+		{
+			name:    "no standby",
+			objs:    []runtime.Object{readyPod("pod-b")},
+			wantErr: ErrNoStandby,
+		},
+		{
+			name:    "standby not ready",
+			objs:    []runtime.Object{testPod("pod-b")},
+			standby: true,
+			wantErr: ErrNoStandby,
+		},
+		{
+			name:    "ready standby",
+			objs:    []runtime.Object{readyPod("pod-b")},
+			standby: true,
+		},
+		{
+			name:    "ready standby with disruptions allowed",
+			objs:    []runtime.Object{readyPod("pod-b"), budget(1)},
+			standby: true,
+		},
+		{
+			name:    "budget allows no disruptions",
+			objs:    []runtime.Object{readyPod("pod-b"), budget(0)},
+			standby: true,
+			wantErr: ErrNoStandby,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cluster := newFakeCluster(clock, append(tc.objs, leader)...)
+			e := newTestElector("lock", cluster, clock, WithSafeStepDown(testStepDownWait))
+			if err := e.campaign(context.Background()); err != nil {
+				t.Fatalf("campaign failed: %s", err.Error())
+			}
+			if tc.standby {
+				cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+					updated, err := withCandidate(cm, "pod-b", clock.Now(), maxCandidates)
+					if err != nil {
+						t.Fatal(err)
+					}
+					*cm = *updated
+				})
+			}
+
+			start := clock.Now()
+			err := await(t, clock, standbyPollInterval, async(func() error { return e.Release(context.Background()) }))
+			if err != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr == nil {
+				if cm := cluster.lock(t, "lock"); cm != nil {
+					t.Errorf("lock still exists, held by %q", lockHolder(cm))
+				}
+				return
+			}
+			if waited := clock.Now().Sub(start); waited < testStepDownWait {
+				t.Errorf("refused after %s, expected to wait %s", waited, testStepDownWait)
+			}
+			if got := lockHolder(cluster.lock(t, "lock")); got != "pod-a" {
+				t.Errorf("lock is held by %q, expected pod-a", got)
+			}
+			if state := e.Status().State; state != StateLeader {
+				t.Errorf("state is %s, expected leader", state)
+			}
+		})
+	}
+}
+
+func TestSafeStepDownAwaitsStandby(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), readyPod("pod-b"))
+	e := newTestElector("lock", cluster, clock, WithSafeStepDown(testStepDownWait))
+	if err := e.campaign(context.Background()); err != nil {
+		t.Fatalf("campaign failed: %s", err.Error())
+	}
+
+	result := async(func() error { return e.Release(context.Background()) })
+	never(t, clock, testStepDownWait/20, result)
+	// a standby appears before the wait is over
+	cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+		updated, err := withCandidate(cm, "pod-b", clock.Now(), maxCandidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*cm = *updated
+	})
+	if err := await(t, clock, standbyPollInterval, result); err != nil {
+		t.Fatalf("Release failed: %s", err.Error())
+	}
+	if cm := cluster.lock(t, "lock"); cm != nil {
+		t.Errorf("lock still exists, held by %q", lockHolder(cm))
+	}
+}
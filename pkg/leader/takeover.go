@@ -0,0 +1,141 @@
+package leader
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// inspectLock looks at the existing lock while waiting to become the leader,
+// and removes it if it can safely be taken over. It returns the lock, if it
+// could be retrieved, and true if the lock is gone and creating it should be
+// tried again right away. An error is returned only if another process uses
+// this Elector's identity.
+func (e *Elector) inspectLock() (*corev1.ConfigMap, bool, error) {
+	cm, err := e.getLock()
+	switch {
+	case err == nil:
+	case apierrors.IsNotFound(err):
+		return nil, true, nil
+	default:
+		e.log().Warnf("failed to get lock: %s", err.Error())
+		return nil, false, nil
+	}
+
+	e.setHolder(lockHolder(cm))
+	e.observeLock(cm)
+	e.observeEpoch(cm)
+	e.observeHandoff(cm)
+	if zone := cm.Annotations[zoneAnnotation]; zone != "" {
+		e.lastLeaderZone = zone
+	}
+	e.observeSuccessor(cm.Annotations)
+	e.checkCompatibility(cm)
+	e.clearStaleFinalizer(cm)
+
+	if e.lockExpired(cm) {
+		e.log().Info("Lock has expired without being refreshed.")
+		return cm, e.forceDeleteLock(cm), nil
+	}
+
+	if e.staleChild(cm) {
+		e.log().Info("Lock was acquired under an earlier term of its parent.")
+		return cm, e.forceDeleteLock(cm), nil
+	}
+
+	if e.holderSucceeded(cm) {
+		e.log().Info("Leader's pod has completed.")
+		return cm, e.forceDeleteLock(cm), nil
+	}
+
+	if e.takeOverTerminating && e.takeOverFromTerminating(cm) {
+		return cm, true, nil
+	}
+
+	if err := e.registerCandidate(cm); err != nil {
+		return cm, false, err
+	}
+	return cm, false, nil
+}
+
+// holderSucceeded returns true if the lock is held by a pod that has run to
+// completion, as happens with Jobs. Such a pod is not deleted right away, but
+// will never act as the leader again. The pod must have the UID recorded for
+// the holder, so that a completed pod that merely reuses the holder's name is
+// not mistaken for it; if no UID is recorded, the holder is not judged.
+func (e *Elector) holderSucceeded(cm *corev1.ConfigMap) bool {
+	holder := lockHolder(cm)
+	uid := holderPodUID(cm, holder)
+	if holder == "" || uid == "" {
+		return false
+	}
+	pod, err := e.client.CoreV1().Pods(e.ns).Get(holder, metav1.GetOptions{})
+	if err != nil || pod.UID != uid {
+		return false
+	}
+	return pod.Status.Phase == corev1.PodSucceeded
+}
+
+// holderPodUID returns the UID of the pod holding the lock: the one recorded
+// by its holder, or else that of the lock's owner reference to the pod.
+func holderPodUID(cm *corev1.ConfigMap, holder string) types.UID {
+	if uid := cm.Annotations[holderUIDAnnotation]; uid != "" {
+		return types.UID(uid)
+	}
+	for _, ref := range cm.GetOwnerReferences() {
+		if ref.Kind == "Pod" && ref.Name == holder {
+			return ref.UID
+		}
+	}
+	return ""
+}
+
+// takeOverFromTerminating deletes the lock if its owner pod is terminating and
+// has not gone away within the allowed time. It returns true if the lock was
+// deleted.
+func (e *Elector) takeOverFromTerminating(cm *corev1.ConfigMap) bool {
+	for _, existingOwner := range cm.GetOwnerReferences() {
+		if existingOwner.Kind != "Pod" {
+			continue
+		}
+		pod, err := e.client.CoreV1().Pods(e.ns).Get(existingOwner.Name, metav1.GetOptions{})
+		if err != nil || pod.UID != existingOwner.UID || pod.DeletionTimestamp == nil {
+			continue
+		}
+		deadline := pod.DeletionTimestamp.Add(e.terminatingSlack)
+		if e.clock.Now().Before(deadline) {
+			e.log().Infof("Leader %s is terminating; waiting until %s to take over.", pod.Name, deadline.Format(time.RFC3339))
+			continue
+		}
+		return e.forceDeleteLock(cm)
+	}
+	return false
+}
+
+// forceDeleteLock deletes a lock held by some other pod, provided it has not
+// been replaced in the meantime, and removes any finalizer that would keep it
+// around. It returns true on success.
+func (e *Elector) forceDeleteLock(cm *corev1.ConfigMap) bool {
+	holder := e.Status().Holder
+	e.log().Infof("Taking over lock from %s.", holder)
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	err := cms.Delete(cm.Name, metav1.NewPreconditionDeleteOptions(string(cm.UID)))
+	if err != nil && !apierrors.IsNotFound(err) {
+		e.log().Warnf("failed to delete lock: %s", err.Error())
+		return false
+	}
+	e.tookOver(holder)
+	if hasFinalizer(cm) {
+		latest, err := cms.Get(cm.Name, metav1.GetOptions{})
+		if err == nil && latest.UID == cm.UID {
+			if err := dropFinalizer(cms.Update, latest); err != nil {
+				e.log().Warnf("failed to remove finalizer: %s", err.Error())
+				return false
+			}
+		}
+	}
+	return true
+}
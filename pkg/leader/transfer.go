@@ -0,0 +1,150 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// successorAnnotation names the candidate that leadership is being
+	// transferred to.
+	successorAnnotation = "leader.mhrivnak.github.io/successor"
+	// successorDeadlineAnnotation is when other candidates stop deferring to
+	// the successor, in RFC 3339 format.
+	successorDeadlineAnnotation = "leader.mhrivnak.github.io/successor-deadline"
+)
+
+const (
+	// transferWindow is how long other candidates defer to a designated
+	// successor once the lock is released.
+	transferWindow = time.Second * 15
+	// transferNotice bounds how long the leader waits, after naming a
+	// successor, for the live candidates to observe it. Candidates look at
+	// the lock when they wake from backoff and record themselves on it every
+	// candidateRefreshInterval, so this covers both.
+	transferNotice = maxBackoff + candidateRefreshInterval
+	// transferCheckInterval is how often the leader checks whether the
+	// candidates have observed the successor.
+	transferCheckInterval = time.Second * 1
+)
+
+// TransferTo hands leadership to the candidate with the provided identity,
+// which is the name of its pod. The successor is recorded on the lock, and the
+// lock is kept until every live candidate has recorded itself on the lock
+// since, which shows it has observed the successor, or until transferNotice
+// has passed. Then the lock is released as with Release. For a short time
+// afterward, other candidates that observed the successor refrain from
+// becoming the leader, so that leadership lands on the successor, for example
+// a pod running a new version during a rolling update.
+//
+// With WithSafeStepDown, ErrNoStandby is returned unless the successor is a
+// healthy standby in time.
+func (e *Elector) TransferTo(ctx context.Context, identity string) error {
+	if e.Status().State != StateLeader {
+		return ErrNotLeader
+	}
+	if err := e.awaitStandby(ctx, identity); err != nil {
+		return err
+	}
+	e.log().Infof("transferring leadership to %s", identity)
+
+	deadline := e.clock.Now().Add(transferNotice + transferWindow)
+	cms := e.client.CoreV1().ConfigMaps(e.ns)
+	var noticed *corev1.ConfigMap
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := cms.Get(e.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if cm.UID != e.lockUID {
+			return ErrNotLeader
+		}
+		cm = cm.DeepCopy()
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[successorAnnotation] = identity
+		cm.Annotations[successorDeadlineAnnotation] = deadline.Format(time.RFC3339)
+		noticed, err = cms.Update(cm)
+		return err
+	})
+	if err != nil {
+		e.log().Error("failed to record successor")
+		return err
+	}
+
+	if err := e.awaitSuccessorNoticed(ctx, noticed); err != nil {
+		return err
+	}
+	return e.release(ctx)
+}
+
+// awaitSuccessorNoticed blocks until every candidate that was live on the
+// provided lock, which names the successor, has recorded itself on the lock
+// again, or until transferNotice has passed. Since candidates update the lock
+// with the resourceVersion they read, an entry that changed was written by a
+// candidate that saw the successor.
+func (e *Elector) awaitSuccessorNoticed(ctx context.Context, noticed *corev1.ConfigMap) error {
+	pending := map[string]time.Time{}
+	for _, c := range liveCandidates(noticed, e.clock.Now()) {
+		pending[c.Identity] = c.LastSeen
+	}
+	start := e.clock.Now()
+	for len(pending) > 0 {
+		if e.since(start) >= transferNotice {
+			e.log().Infof("%d candidates have not observed the successor; releasing anyway", len(pending))
+			return nil
+		}
+		select {
+		case <-e.sleeper.After(transferCheckInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		cm, err := e.client.CoreV1().ConfigMaps(e.ns).Get(e.name, metav1.GetOptions{})
+		if err != nil {
+			e.log().Warnf("failed to check whether candidates observed the successor: %s", err.Error())
+			continue
+		}
+		if cm.UID != e.lockUID {
+			return ErrNotLeader
+		}
+		for _, c := range lockCandidates(cm) {
+			if seen, ok := pending[c.Identity]; ok && !c.LastSeen.Equal(seen) {
+				delete(pending, c.Identity)
+			}
+		}
+	}
+	return nil
+}
+
+// observeSuccessor records the successor named on the lock, if any.
+func (e *Elector) observeSuccessor(annotations map[string]string) {
+	successor := annotations[successorAnnotation]
+	if successor == "" {
+		return
+	}
+	deadline, err := time.Parse(time.RFC3339, annotations[successorDeadlineAnnotation])
+	if err != nil {
+		return
+	}
+	e.successor = successor
+	e.successorDeadline = deadline
+}
+
+// deferringTo returns the successor this Elector should currently defer to,
+// and true if it should defer.
+func (e *Elector) deferringTo() (string, bool) {
+	if e.successor == "" || e.successor == e.identity {
+		return "", false
+	}
+	if !e.clock.Now().Before(e.successorDeadline) {
+		e.successor = ""
+		return "", false
+	}
+	return e.successor, true
+}
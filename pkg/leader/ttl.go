@@ -0,0 +1,91 @@
+package leader
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// expiresAtAnnotation is when a lock with a TTL expires unless refreshed, in
+// RFC 3339 format, by the leader's clock. Candidates only watch it for changes,
+// so clock skew does not matter.
+const expiresAtAnnotation = "leader.mhrivnak.github.io/expires-at"
+
+// ttlAnnotation is the TTL of a lock, as a Go duration string. Candidates treat
+// the lock as abandoned once its expiry has gone unrefreshed for that long.
+const ttlAnnotation = "leader.mhrivnak.github.io/ttl"
+
+// checkInterval is how often the leader checks its lock. With a TTL, the lock
+// is refreshed at each check, so checks happen often enough to refresh it
+// several times before it expires.
+func (e *Elector) checkInterval() time.Duration {
+	if e.leaseDuration > 0 && e.leaseDuration/3 < lockCheckInterval {
+		return e.leaseDuration / 3
+	}
+	if e.lockTTL > 0 && e.lockTTL/3 < lockCheckInterval {
+		return e.lockTTL / 3
+	}
+	return lockCheckInterval
+}
+
+// expiry returns the expiry to record on the lock, as of now.
+func (e *Elector) expiry() string {
+	return e.clock.Now().Add(e.lockTTL).UTC().Format(time.RFC3339)
+}
+
+// stampExpiry records the TTL and an expiry, as of now, on a lock this Elector
+// is about to create.
+func (e *Elector) stampExpiry(annotations map[string]string) {
+	e.refreshedAt = e.clock.Now()
+	annotations[expiresAtAnnotation] = e.expiry()
+	annotations[ttlAnnotation] = e.lockTTL.String()
+}
+
+// refreshExpiry extends the expiry recorded on the lock, retrying if a
+// candidate updates the lock at the same time. On failure, the next check
+// tries again, unless ttlExpired by then.
+func (e *Elector) refreshExpiry(cm *corev1.ConfigMap) error {
+	now := e.clock.Now()
+	_, err := e.updateLock(cm, func(cm *corev1.ConfigMap) error {
+		cm.Annotations[expiresAtAnnotation] = e.expiry()
+		cm.Annotations[ttlAnnotation] = e.lockTTL.String()
+		if e.detectConflicts {
+			cm.Annotations[heartbeatAnnotation] = now.UTC().Format(time.RFC3339)
+		}
+		return nil
+	})
+	if err != nil {
+		return e.permissionError(err, "update", "configmaps")
+	}
+	e.refreshedAt = now
+	return nil
+}
+
+// ttlExpired returns true if this Elector holds a lock with a TTL that it has
+// not refreshed for so long that, by the next check, candidates may have taken
+// it over.
+func (e *Elector) ttlExpired() bool {
+	return e.lockTTL > 0 && e.since(e.refreshedAt)+e.checkInterval() >= e.lockTTL
+}
+
+// lockExpired returns true if the lock has a TTL and its expiry has gone
+// unchanged for that long, as observed by this Elector, meaning its holder has
+// stopped refreshing it. As with leases, only this Elector's clock is used.
+func (e *Elector) lockExpired(cm *corev1.ConfigMap) bool {
+	expiry, ok := cm.Annotations[expiresAtAnnotation]
+	if !ok {
+		return false
+	}
+	ttl, err := time.ParseDuration(cm.Annotations[ttlAnnotation])
+	if err != nil {
+		ttl = e.lockTTL
+	}
+	if ttl <= 0 {
+		return false
+	}
+	if version := string(cm.UID) + "/" + expiry; version != e.expiryVersion {
+		e.expiryVersion = version
+		e.expiryObservedAt = e.clock.Now()
+	}
+	return e.since(e.expiryObservedAt) >= ttl
+}
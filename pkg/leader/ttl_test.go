@@ -0,0 +1,182 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const testLockTTL = time.Second * 30
+
+func TestLockTTLExpiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+	}{
+		{
+			name:      "expired",
+			expiresIn: -time.Second,
+		},
+		{
+			name:      "expires while waiting",
+			expiresIn: testLockTTL,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			expiresAt := clock.Now().Add(tc.expiresIn)
+			lock := testLock("lock", "pod-b", map[string]string{
+				expiresAtAnnotation: expiresAt.UTC().Format(time.RFC3339),
+			})
+			cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), lock)
+			e := newTestElector("lock", cluster, clock, WithLockTTL(testLockTTL))
+
+			result := async(func() error { return e.Become(context.Background()) })
+			if err := await(t, clock, time.Second, result); err != nil {
+				t.Fatalf("Become failed: %s", err.Error())
+			}
+			if !clock.Now().After(expiresAt) {
+				t.Errorf("lock was taken over at %s, before it expired at %s", clock.Now(), expiresAt)
+			}
+
+			cm := cluster.lock(t, "lock")
+			if got := lockHolder(cm); got != "pod-a" {
+				t.Errorf("lock is held by %q, expected pod-a", got)
+			}
+			if got := cm.Annotations[expiresAtAnnotation]; e.lockExpired(cm) || got > e.expiry() {
+				t.Errorf("lock expires at %s, expected within %s of %s", got, testLockTTL, clock.Now())
+			}
+		})
+	}
+}
+
+func TestLockTTLRefresh(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"))
+	e := newTestElector("lock", cluster, clock, WithLockTTL(testLockTTL))
+	if err := e.campaign(context.Background()); err != nil {
+		t.Fatalf("campaign failed: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return e.maintain(ctx) })
+	// the lock is refreshed as long as it is held, well past its TTL
+	end := clock.Now().Add(3 * testLockTTL)
+	for clock.Now().Before(end) {
+		expiry := cluster.lock(t, "lock").Annotations[expiresAtAnnotation]
+		eventually(t, clock, e.checkInterval(), func() bool {
+			return cluster.lock(t, "lock").Annotations[expiresAtAnnotation] > expiry
+		})
+	}
+	if cm := cluster.lock(t, "lock"); e.lockExpired(cm) {
+		t.Errorf("lock expired at %s", cm.Annotations[expiresAtAnnotation])
+	}
+
+	// once the lock is not refreshed, a candidate takes it over
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	clock.Step(testLockTTL + time.Second)
+	other := newTestElector("lock", cluster, clock, WithHostname("pod-b"))
+	if err := await(t, clock, time.Second, async(func() error { return other.Become(context.Background()) })); err != nil {
+		t.Fatalf("Become failed: %s", err.Error())
+	}
+	if got := lockHolder(cluster.lock(t, "lock")); got != "pod-b" {
+		t.Errorf("lock is held by %q, expected pod-b", got)
+	}
+}
+
+func TestLockTTLSkew(t *testing.T) {
+	clock := newFakeClock()
+	// the leader's clock is an hour behind, so by this clock its lock always
+	// looks expired
+	behind := clock.Now().Add(-time.Hour)
+	lock := testLock("lock", "pod-b", map[string]string{
+		expiresAtAnnotation: behind.UTC().Format(time.RFC3339),
+		ttlAnnotation:       testLockTTL.String(),
+	})
+	cluster := newFakeCluster(clock, testPod("pod-a"), testPod("pod-b"), lock)
+	e := newTestElector("lock", cluster, clock, WithLockTTL(testLockTTL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return e.Become(ctx) })
+	for i := 0; i < 6; i++ {
+		behind = behind.Add(testLockTTL / 3)
+		cluster.modify(t, "lock", func(cm *corev1.ConfigMap) {
+			cm.Annotations[expiresAtAnnotation] = behind.UTC().Format(time.RFC3339)
+		})
+		never(t, clock, testLockTTL/30, result)
+	}
+	if got := lockHolder(cluster.lock(t, "lock")); got != "pod-b" {
+		t.Errorf("lock is held by %q, expected pod-b", got)
+	}
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLockTTLStepDown(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	e := newTestElector("lock", cluster, clock, WithLockTTL(testLockTTL))
+	if err := e.campaign(context.Background()); err != nil {
+		t.Fatalf("campaign failed: %s", err.Error())
+	}
+
+	result := async(func() error { return e.maintain(context.Background()) })
+	never(t, clock, e.checkInterval(), result)
+	refreshed := clock.Now()
+	cluster.setUnreachable(true)
+	if err := await(t, clock, e.checkInterval(), result); err != ErrLeadershipLost {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if led := clock.Now().Sub(refreshed); led >= testLockTTL {
+		t.Errorf("led for %s after the lock was last refreshed, expected less than %s", led, testLockTTL)
+	}
+	if state := e.Status().State; state != StateCandidate {
+		t.Errorf("state is %s, expected candidate", state)
+	}
+}
+
+func TestLockTTLRefreshConflict(t *testing.T) {
+	clock := newFakeClock()
+	cluster := newFakeCluster(clock, testPod("pod-a"))
+	e := newTestElector("lock", cluster, clock, WithLockTTL(testLockTTL))
+	if err := e.campaign(context.Background()); err != nil {
+		t.Fatalf("campaign failed: %s", err.Error())
+	}
+	// pod-b records itself as a candidate as the lock is refreshed
+	interfere(t, cluster, "lock", func(cm *corev1.ConfigMap) {
+		updated, err := withCandidate(cm, "pod-b", clock.Now(), maxCandidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cm.Annotations[candidatesAnnotation] = updated.Annotations[candidatesAnnotation]
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := async(func() error { return e.maintain(ctx) })
+	expiry := cluster.lock(t, "lock").Annotations[expiresAtAnnotation]
+	eventually(t, clock, e.checkInterval(), func() bool {
+		return cluster.lock(t, "lock").Annotations[expiresAtAnnotation] > expiry
+	})
+	if candidates := lockCandidates(cluster.lock(t, "lock")); len(candidates) != 1 || candidates[0].Identity != "pod-b" {
+		t.Errorf("candidates are %v, expected pod-b", candidates)
+	}
+	if err := e.Status().LastError; err != nil {
+		t.Errorf("refresh failed: %s", err.Error())
+	}
+	cancel()
+	if err := await(t, clock, 0, result); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
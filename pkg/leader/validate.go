@@ -0,0 +1,114 @@
+package leader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ConfigError lists every problem found in an Elector's options by Validate.
+// Each problem names the option it concerns.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid leader election configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the Elector's name and options, without making any API
+// requests, and returns a *ConfigError listing every problem found. Become and
+// the other ways of running an election call it before they start, so that
+// misconfigurations surface together rather than one at a time.
+func (e *Elector) Validate() error {
+	var problems []string
+	problem := func(option, format string, args ...interface{}) {
+		problems = append(problems, option+": "+fmt.Sprintf(format, args...))
+	}
+	nonNegative := func(option string, d time.Duration) {
+		if d < 0 {
+			problem(option, "must not be negative, got %s", d)
+		}
+	}
+
+	if err := ValidateName(e.name); err != nil {
+		problem("name", "%s", err.Error())
+	} else if strings.HasSuffix(e.name, epochCounterSuffix) {
+		problem("name", "lock name %q must not end with %q, which names epoch counters", e.name, epochCounterSuffix)
+	}
+	if e.ns != "" {
+		if errs := validation.IsDNS1123Label(e.ns); len(errs) > 0 {
+			problem("WithNamespace", "invalid namespace %q: %s", e.ns, strings.Join(errs, "; "))
+		}
+	}
+
+	nonNegative("WithLockTTL", e.lockTTL)
+	nonNegative("WithLease", e.leaseDuration)
+	nonNegative("WithOutagePolicy", e.outageTolerance)
+	nonNegative("WithSafeStepDown", e.stepDownWait)
+	nonNegative("WithTerminatingLeaderSlack", e.terminatingSlack)
+	nonNegative("WithOrdinalPreference", e.ordinalStep)
+	nonNegative("WithStartupJitter", e.startupJitter)
+	nonNegative("WithDedupeWindow", e.dedupeWindow)
+	nonNegative("WithStandby", e.standbyAfter)
+	if e.pollInterval <= 0 {
+		problem("WithPollInterval", "must be positive, got %s", e.pollInterval)
+	}
+
+	if id, ok := e.static(); ok && id.Name == "" {
+		problem("WithIdentity", "identity must not be empty")
+	}
+	if e.externalIdentity() {
+		if e.jobOwner {
+			problem("WithIdentity", "cannot be combined with WithJobOwner")
+		}
+		if e.leaseDuration <= 0 && len(e.backends) == 0 {
+			problem("WithIdentity", "%s", ErrIdentityWithoutLease.Error())
+		}
+	}
+	for _, backend := range e.backends {
+		switch backend {
+		case BackendConfigMapLease:
+		case BackendConfigMap:
+			if e.externalIdentity() {
+				problem("WithBackends", "backend %s needs a pod to own the lock, which WithIdentity does not provide", backend)
+			}
+		default:
+			problem("WithBackends", "unknown backend %q", backend)
+		}
+	}
+
+	if e.legacyName != "" {
+		if err := ValidateName(e.legacyName); err != nil {
+			problem("WithLegacyLockName", "%s", err.Error())
+		} else if e.legacyName == e.name {
+			problem("WithLegacyLockName", "legacy name must differ from %q", e.name)
+		}
+	}
+	if e.leaderLabelKey != "" {
+		if errs := validation.IsQualifiedName(e.leaderLabelKey); len(errs) > 0 {
+			problem("WithLeaderLabel", "invalid key %q: %s", e.leaderLabelKey, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(e.leaderLabelValue); len(errs) > 0 {
+			problem("WithLeaderLabel", "invalid value %q: %s", e.leaderLabelValue, strings.Join(errs, "; "))
+		}
+	}
+	if e.readinessGate != "" {
+		if errs := validation.IsQualifiedName(e.readinessGate); len(errs) > 0 {
+			problem("WithReadinessGate", "invalid condition type %q: %s", e.readinessGate, strings.Join(errs, "; "))
+		}
+	}
+	if e.peerPort < 0 || e.peerPort > 65535 {
+		problem("WithPeerPort", "invalid port %d", e.peerPort)
+	}
+	if e.pushURL != "" && e.pushJob == "" {
+		problem("WithPushgateway", "job must not be empty")
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package leader
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Version is the version of this library, recorded on each lock it creates.
+const Version = "0.2.0"
+
+const (
+	// versionAnnotation records the version of the library that created the
+	// lock.
+	versionAnnotation = "leader.mhrivnak.github.io/version"
+	// featuresAnnotation records the comma-separated features, affecting
+	// how other candidates take over, that the holder has enabled.
+	featuresAnnotation = "leader.mhrivnak.github.io/features"
+)
+
+// Features that change how candidates decide the lock can be taken over. A
+// candidate that does not share the holder's setting may wait forever, or take
+// over too soon.
+const (
+	featureLease = "lease"
+	featureTTL   = "ttl"
+)
+
+// features returns the features this Elector has enabled, sorted.
+func (e *Elector) features() []string {
+	features := []string{}
+	if e.leaseDuration > 0 {
+		features = append(features, featureLease)
+	}
+	if e.lockTTL > 0 {
+		features = append(features, featureTTL)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// stampVersion records the library version and enabled features in the
+// provided annotations.
+func (e *Elector) stampVersion(annotations map[string]string) {
+	annotations[versionAnnotation] = Version
+	annotations[featuresAnnotation] = strings.Join(e.features(), ",")
+}
+
+// checkCompatibility logs a warning if the holder of the provided lock has
+// enabled different features than this Elector, once per lock. Locks created
+// by versions that did not record their features are not checked.
+func (e *Elector) checkCompatibility(cm *corev1.ConfigMap) {
+	value, ok := cm.Annotations[featuresAnnotation]
+	if !ok || cm.UID == e.checkedLockUID {
+		return
+	}
+	e.checkedLockUID = cm.UID
+
+	mine := strings.Join(e.features(), ",")
+	if value != mine {
+		e.log().Warnf("Leader %s runs version %s with features [%s], but this candidate has [%s]; takeover may not behave as expected.",
+			lockHolder(cm), cm.Annotations[versionAnnotation], value, mine)
+	}
+}
@@ -0,0 +1,107 @@
+package leader
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// defaultPollInterval is how often candidates that may not watch pods check on
+// the lock, unless set with WithPollInterval.
+const defaultPollInterval = time.Second * 5
+
+// ownerWatch watches the pod that owns the lock, so that a waiting candidate
+// can try to become the leader as soon as that pod is deleted.
+type ownerWatch struct {
+	uid     types.UID
+	watcher watch.Interface
+	// release is set instead of watcher when the watch is shared by a Manager
+	release func()
+	// deleted is closed when the pod is deleted. The wait loop sets it to nil
+	// once it has been received, so that it only fires once.
+	deleted chan struct{}
+	// done is closed when the watch ends for any reason
+	done chan struct{}
+}
+
+func (w *ownerWatch) stop() {
+	switch {
+	case w == nil:
+	case w.release != nil:
+		w.release()
+	default:
+		w.watcher.Stop()
+	}
+}
+
+// watchOwner returns a watch on the pod that owns the provided lock. If w
+// already watches that pod, it is returned unchanged; otherwise it is stopped
+// and replaced. nil is returned if the lock has no pod owner or the watch can't
+// be established, in which case the caller relies on polling alone.
+func (e *Elector) watchOwner(w *ownerWatch, cm *corev1.ConfigMap) *ownerWatch {
+	var owner *metav1.OwnerReference
+	for i, ref := range cm.GetOwnerReferences() {
+		if ref.Kind == "Pod" {
+			owner = &cm.GetOwnerReferences()[i]
+			break
+		}
+	}
+	if owner == nil {
+		w.stop()
+		return nil
+	}
+	if e.pollPods {
+		return nil
+	}
+	if w != nil && w.uid == owner.UID {
+		select {
+		case <-w.done:
+			// the watch expired; start a new one
+		default:
+			return w
+		}
+	}
+	w.stop()
+
+	if e.shared != nil {
+		return e.shared.watchPod(owner.UID)
+	}
+	watcher, err := e.client.CoreV1().Pods(e.ns).Watch(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", owner.Name).String(),
+	})
+	if apierrors.IsForbidden(err) {
+		e.log().Warnf("not allowed to watch pods; polling every %s instead", e.pollInterval)
+		e.pollPods = true
+		return nil
+	}
+	if err != nil {
+		e.log().Warnf("failed to watch leader pod: %s", err.Error())
+		return nil
+	}
+
+	w = &ownerWatch{
+		uid:     owner.UID,
+		watcher: watcher,
+		deleted: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func(uid types.UID, deleted, done chan struct{}) {
+		defer close(done)
+		for event := range watcher.ResultChan() {
+			if event.Type != watch.Deleted {
+				continue
+			}
+			pod, ok := event.Object.(*corev1.Pod)
+			if ok && pod.UID == uid {
+				close(deleted)
+				return
+			}
+		}
+	}(w.uid, w.deleted, w.done)
+	return w
+}
@@ -0,0 +1,147 @@
+// Package leaderproxy provides an HTTP handler that forwards requests to the
+// pod currently holding a lock created by the leader package. It lets every
+// replica of an active/standby service accept traffic while only the leader
+// serves it.
+package leaderproxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+
+	"github.com/mhrivnak/leaderelection/pkg/leader"
+	"github.com/sirupsen/logrus"
+)
+
+// resolveInterval is how long a resolved leader address is used before the
+// lock is looked up again.
+const resolveInterval = 2 * time.Second
+
+// ErrNoLeaderAddress indicates that the address of the leader could not be
+// determined, for example because there is no leader at the moment.
+var ErrNoLeaderAddress = errors.New("leader address not found")
+
+// Proxy is an http.Handler that forwards each request to the current leader
+// of a lock. The leader's address is taken from the lock and looked up again
+// every few seconds, and right away when forwarding a request fails, so that
+// requests follow leadership as it changes.
+type Proxy struct {
+	admin     *leader.Admin
+	client    k8sclient.Interface
+	namespace string
+	lock      string
+	port      int
+
+	// Scheme is the scheme used to reach the leader. It defaults to "http".
+	Scheme string
+	// Transport is used to forward requests. It defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+	// Clock tells when a resolved address is due to be looked up again. It
+	// defaults to the system clock.
+	Clock leader.Clock
+
+	mu         sync.Mutex
+	target     *url.URL
+	resolvedAt time.Time
+}
+
+// New returns a Proxy that forwards requests to the provided port of the pod
+// holding the named lock in namespace.
+func New(client k8sclient.Interface, namespace, lock string, port int) *Proxy {
+	return &Proxy{
+		admin:     leader.NewAdmin(client),
+		client:    client,
+		namespace: namespace,
+		lock:      lock,
+		port:      port,
+		Scheme:    "http",
+	}
+}
+
+// ServeHTTP forwards the request to the current leader. If the leader can't be
+// determined, 503 Service Unavailable is returned.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target, err := p.leader()
+	if err != nil {
+		logrus.Warnf("failed to find leader of %s/%s: %s", p.namespace, p.lock, err.Error())
+		http.Error(w, "no leader available", http.StatusServiceUnavailable)
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = p.Transport
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logrus.Warnf("failed to reach leader at %s: %s", target.Host, err.Error())
+		p.invalidate(target)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// leader returns the URL of the current leader, looking it up if the last
+// lookup is too old.
+func (p *Proxy) leader() (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.Clock != nil {
+		now = p.Clock.Now()
+	}
+	if p.target != nil && now.Sub(p.resolvedAt) < resolveInterval {
+		return p.target, nil
+	}
+
+	address, err := p.resolve()
+	if err != nil {
+		p.target = nil
+		return nil, err
+	}
+	p.target = &url.URL{
+		Scheme: p.Scheme,
+		Host:   net.JoinHostPort(address, strconv.Itoa(p.port)),
+	}
+	p.resolvedAt = now
+	return p.target, nil
+}
+
+// resolve returns the IP address of the current leader. Locks that do not
+// record an address are resolved through the holder's pod.
+func (p *Proxy) resolve() (string, error) {
+	info, err := p.admin.Get(p.namespace, p.lock)
+	if err != nil {
+		return "", err
+	}
+	if info.Address != "" {
+		return info.Address, nil
+	}
+	if info.HolderKind != "Pod" {
+		return "", ErrNoLeaderAddress
+	}
+	pod, err := p.client.CoreV1().Pods(p.namespace).Get(info.Holder, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if pod.Status.PodIP == "" {
+		return "", ErrNoLeaderAddress
+	}
+	return pod.Status.PodIP, nil
+}
+
+// invalidate forgets the resolved leader if it is still target, so that the
+// next request looks it up again.
+func (p *Proxy) invalidate(target *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.target == target {
+		p.target = nil
+	}
+}
@@ -0,0 +1,180 @@
+package leaderproxy
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "test"
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testLock returns a lock held by the named pod, with the provided
+// annotations.
+func testLock(pod string, annotations map[string]string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "lock",
+			Namespace:   testNamespace,
+			Annotations: map[string]string{"leader.mhrivnak.github.io/holder": pod},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       pod,
+				UID:        "uid",
+			}},
+		},
+	}
+	for k, v := range annotations {
+		cm.Annotations[k] = v
+	}
+	return cm
+}
+
+// testPod returns a pod with the provided IP address.
+func testPod(name, ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+// serve returns a server that responds with its name, and its port.
+func serve(t *testing.T, name string) (*httptest.Server, int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	}))
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, n
+}
+
+// get sends a request through the proxy and returns the status and body of
+// the response.
+func get(t *testing.T, p *Proxy) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	p.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	body, err := ioutil.ReadAll(recorder.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, string(body)
+}
+
+func TestProxy(t *testing.T) {
+	server, port := serve(t, "leader")
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		objs       []runtime.Object
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "address recorded on the lock",
+			objs:       []runtime.Object{testLock("pod-a", map[string]string{"leader.mhrivnak.github.io/address": "127.0.0.1"})},
+			wantStatus: http.StatusOK,
+			wantBody:   "leader",
+		},
+		{
+			name:       "address of the holder's pod",
+			objs:       []runtime.Object{testLock("pod-a", nil), testPod("pod-a", "127.0.0.1")},
+			wantStatus: http.StatusOK,
+			wantBody:   "leader",
+		},
+		{
+			name:       "holder's pod has no address yet",
+			objs:       []runtime.Object{testLock("pod-a", nil), testPod("pod-a", "")},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "no leader",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := New(fake.NewSimpleClientset(tc.objs...), testNamespace, "lock", port)
+			status, body := get(t, p)
+			if status != tc.wantStatus {
+				t.Errorf("status is %d, expected %d", status, tc.wantStatus)
+			}
+			if tc.wantBody != "" && body != tc.wantBody {
+				t.Errorf("body is %q, expected %q", body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestProxyFollowsLeader(t *testing.T) {
+	server, port := serve(t, "leader")
+	defer server.Close()
+	client := fake.NewSimpleClientset(testLock("pod-a", nil), testPod("pod-a", "127.0.0.1"), testPod("pod-b", "127.0.0.2"))
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p := New(client, testNamespace, "lock", port)
+	p.Clock = clock
+
+	if status, _ := get(t, p); status != http.StatusOK {
+		t.Fatalf("status is %d, expected %d", status, http.StatusOK)
+	}
+
+	// pod-b, at an address with nothing listening, takes over
+	if err := client.CoreV1().ConfigMaps(testNamespace).Delete("lock", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().ConfigMaps(testNamespace).Create(testLock("pod-b", nil)); err != nil {
+		t.Fatal(err)
+	}
+	// the resolved leader is used until it is due to be looked up again
+	if status, body := get(t, p); status != http.StatusOK || body != "leader" {
+		t.Errorf("response is %d %q, expected the previous leader", status, body)
+	}
+	clock.Step(resolveInterval)
+	if status, _ := get(t, p); status != http.StatusBadGateway {
+		t.Errorf("status is %d, expected %d", status, http.StatusBadGateway)
+	}
+
+	// a failure to reach the leader makes the next request look it up again
+	if _, err := client.CoreV1().Pods(testNamespace).Update(testPod("pod-b", "127.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := get(t, p); status != http.StatusOK || body != "leader" {
+		t.Errorf("response is %d %q, expected the new leader", status, body)
+	}
+}
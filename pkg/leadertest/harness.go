@@ -0,0 +1,331 @@
+// Package leadertest runs elections against a real API server, such as one
+// started by envtest or a kind cluster, and provides helpers for asserting on
+// their outcome from tests:
+//
+//	h := leadertest.New(t, config, "myapp-lock")
+//	defer h.Close()
+//	h.StartCandidates(3)
+//	h.EventuallyLeader()
+//	killed := h.KillLeader()
+//	if h.EventuallyLeader() == killed {
+//		t.Fatal("leadership did not move")
+//	}
+//	h.ExpectSingleLeader()
+//
+// Each candidate is an Elector running in the test process, backed by a pod
+// object in a namespace created for the Harness.
+package leadertest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sclient "k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+
+	"github.com/mhrivnak/leaderelection/pkg/leader"
+)
+
+const (
+	// DefaultTimeout is how long the Eventually helpers wait by default.
+	DefaultTimeout = time.Second * 30
+	// pollInterval is how often the helpers check the lock.
+	pollInterval = time.Millisecond * 250
+	// podImage is the image of candidate pods. The pods do not need to run,
+	// but clusters with a scheduler will run them.
+	podImage = "k8s.gcr.io/pause:3.1"
+)
+
+// Candidate is an Elector running in the test process on behalf of a pod.
+type Candidate struct {
+	// Pod is the name of the candidate's pod, which is also its identity.
+	Pod string
+	// Elector is the candidate's Elector.
+	Elector *leader.Elector
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Err returns the error returned by the candidate's election, once it has
+// ended.
+func (c *Candidate) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Harness runs candidates for a single lock in a dedicated namespace.
+type Harness struct {
+	t         testing.TB
+	client    k8sclient.Interface
+	namespace string
+	lock      string
+
+	// Timeout is how long the Eventually helpers wait. It defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+	// EmulateGC makes KillLeader delete the lock itself after deleting the
+	// leader's pod, for API servers without a garbage collector, such as
+	// envtest's. It should be false for kind and other full clusters.
+	EmulateGC bool
+
+	mu         sync.Mutex
+	candidates []*Candidate
+	killed     map[string]bool
+}
+
+// New creates a namespace using the provided config and returns a Harness that
+// runs candidates for the named lock in it. Close removes the namespace.
+func New(t testing.TB, config *restclient.Config, lock string) *Harness {
+	t.Helper()
+	client, err := k8sclient.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err.Error())
+	}
+	return newHarness(t, client, lock)
+}
+
+func newHarness(t testing.TB, client k8sclient.Interface, lock string) *Harness {
+	t.Helper()
+	ns, err := client.CoreV1().Namespaces().Create(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "leadertest-"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create namespace: %s", err.Error())
+	}
+	return &Harness{
+		t:         t,
+		client:    client,
+		namespace: ns.Name,
+		lock:      lock,
+		Timeout:   DefaultTimeout,
+		killed:    map[string]bool{},
+	}
+}
+
+// Client returns the client used by the Harness and its candidates.
+func (h *Harness) Client() k8sclient.Interface {
+	return h.client
+}
+
+// Namespace returns the namespace created for the Harness.
+func (h *Harness) Namespace() string {
+	return h.namespace
+}
+
+// StartCandidates creates n pods and starts a candidate for each. Each
+// candidate runs its election with Elector.Run, campaigning again whenever it
+// loses leadership, until it is killed or the Harness is closed. The options
+// are applied to each candidate's Elector after those set by the Harness.
+func (h *Harness) StartCandidates(n int, opts ...leader.Option) []*Candidate {
+	h.t.Helper()
+	started := []*Candidate{}
+	for i := 0; i < n; i++ {
+		pod, err := h.client.CoreV1().Pods(h.namespace).Create(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "candidate-"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "pause", Image: podImage}},
+			},
+		})
+		if err != nil {
+			h.t.Fatalf("failed to create candidate pod: %s", err.Error())
+		}
+
+		electorOpts := append([]leader.Option{
+			leader.WithNamespace(h.namespace),
+			leader.WithClient(h.client),
+			leader.WithHostname(pod.Name),
+			leader.WithPodNameFile(""),
+			leader.WithoutPreflight(),
+		}, opts...)
+		ctx, cancel := context.WithCancel(context.Background())
+		c := &Candidate{
+			Pod:     pod.Name,
+			Elector: leader.NewElector(h.lock, electorOpts...),
+			cancel:  cancel,
+			done:    make(chan struct{}),
+		}
+		go func() {
+			defer close(c.done)
+			c.err = c.Elector.Run(ctx)
+		}()
+		started = append(started, c)
+	}
+
+	h.mu.Lock()
+	h.candidates = append(h.candidates, started...)
+	h.mu.Unlock()
+	return started
+}
+
+// Candidates returns every candidate started so far, including killed ones.
+func (h *Harness) Candidates() []*Candidate {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*Candidate{}, h.candidates...)
+}
+
+// EventuallyLeader waits until a candidate that has not been killed holds the
+// lock, and returns the name of its pod. The test fails if that does not
+// happen within the Harness's Timeout.
+func (h *Harness) EventuallyLeader() string {
+	h.t.Helper()
+	var holder string
+	err := wait.PollImmediate(pollInterval, h.Timeout, func() (bool, error) {
+		c, err := h.holder()
+		if err != nil || c == nil {
+			return false, nil
+		}
+		if c.Elector.Status().State != leader.StateLeader {
+			return false, nil
+		}
+		holder = c.Pod
+		return true, nil
+	})
+	if err != nil {
+		h.t.Fatalf("no candidate became the leader of %s within %s", h.lock, h.Timeout)
+	}
+	return holder
+}
+
+// KillLeader cancels the current leader's election and deletes its pod, as
+// happens when the leader's pod is evicted, and returns the name of the pod.
+// The test fails if there is no leader.
+func (h *Harness) KillLeader() string {
+	h.t.Helper()
+	c, err := h.holder()
+	if err != nil {
+		h.t.Fatalf("failed to get lock: %s", err.Error())
+	}
+	if c == nil {
+		h.t.Fatalf("no candidate holds %s", h.lock)
+	}
+
+	h.mu.Lock()
+	h.killed[c.Pod] = true
+	h.mu.Unlock()
+	h.stop(c)
+
+	propagation := metav1.DeletePropagationBackground
+	err = h.client.CoreV1().Pods(h.namespace).Delete(c.Pod, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		h.t.Fatalf("failed to delete pod %s: %s", c.Pod, err.Error())
+	}
+	if h.EmulateGC {
+		h.deleteLockOwnedBy(c.Pod)
+	}
+	return c.Pod
+}
+
+// ExpectSingleLeader fails the test unless exactly one candidate that has not
+// been killed considers itself the leader, and it is the lock's holder.
+func (h *Harness) ExpectSingleLeader() {
+	h.t.Helper()
+	c, err := h.holder()
+	if err != nil {
+		h.t.Fatalf("failed to get lock: %s", err.Error())
+	}
+
+	leaders := []string{}
+	for _, candidate := range h.Candidates() {
+		if h.isKilled(candidate.Pod) {
+			continue
+		}
+		if candidate.Elector.Status().State == leader.StateLeader {
+			leaders = append(leaders, candidate.Pod)
+		}
+	}
+	switch {
+	case len(leaders) != 1:
+		h.t.Fatalf("expected a single leader of %s, found %d: %v", h.lock, len(leaders), leaders)
+	case c == nil:
+		h.t.Fatalf("%s considers itself the leader, but no candidate holds %s", leaders[0], h.lock)
+	case c.Pod != leaders[0]:
+		h.t.Fatalf("%s considers itself the leader, but %s holds %s", leaders[0], c.Pod, h.lock)
+	}
+}
+
+// Close stops every candidate, waiting for their elections to end, and deletes
+// the namespace.
+func (h *Harness) Close() {
+	candidates := h.Candidates()
+	for _, c := range candidates {
+		c.cancel()
+	}
+	for _, c := range candidates {
+		h.stop(c)
+	}
+	err := h.client.CoreV1().Namespaces().Delete(h.namespace, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		h.t.Errorf("failed to delete namespace %s: %s", h.namespace, err.Error())
+	}
+}
+
+// stop cancels the candidate's election and waits for it to end, for up to
+// the Harness's Timeout.
+func (h *Harness) stop(c *Candidate) {
+	c.cancel()
+	select {
+	case <-c.done:
+	case <-time.After(h.Timeout):
+		h.t.Errorf("election of %s did not end within %s", c.Pod, h.Timeout)
+	}
+}
+
+// holder returns the candidate holding the lock, or nil if the lock does not
+// exist or is held by a killed candidate or some other process.
+func (h *Harness) holder() (*Candidate, error) {
+	info, err := leader.NewAdmin(h.client).Get(h.namespace, h.lock)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if h.isKilled(info.Holder) {
+		return nil, nil
+	}
+	for _, c := range h.Candidates() {
+		if c.Pod == info.Holder {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *Harness) isKilled(pod string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.killed[pod]
+}
+
+// deleteLockOwnedBy deletes the lock if it is owned by the named pod, as the
+// garbage collector would.
+func (h *Harness) deleteLockOwnedBy(pod string) {
+	cms := h.client.CoreV1().ConfigMaps(h.namespace)
+	cm, err := cms.Get(h.lock, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+	for _, owner := range cm.GetOwnerReferences() {
+		if owner.Name != pod {
+			continue
+		}
+		err := cms.Delete(h.lock, metav1.NewPreconditionDeleteOptions(string(cm.UID)))
+		if err != nil && !apierrors.IsNotFound(err) {
+			h.t.Fatalf("failed to delete lock: %s", err.Error())
+		}
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package leadertest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeClient returns a fake clientset that, like an API server, generates
+// names and assigns UIDs to the objects it creates.
+func newFakeClient() *fake.Clientset {
+	tracker := k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+	client := &fake.Clientset{}
+	client.AddReactor("*", "*", k8stesting.ObjectReaction(tracker))
+	client.AddWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := tracker.Watch(action.GetResource(), action.GetNamespace())
+		return true, w, err
+	})
+
+	var mu sync.Mutex
+	created := 0
+	client.PrependReactor("create", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject()
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return true, nil, err
+		}
+		mu.Lock()
+		created++
+		n := strconv.Itoa(created)
+		mu.Unlock()
+		if m.GetName() == "" && m.GetGenerateName() != "" {
+			m.SetName(m.GetGenerateName() + n)
+		}
+		m.SetUID(types.UID("uid-" + n))
+		if err := tracker.Create(action.GetResource(), obj, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+	return client
+}
+
+func TestHarness(t *testing.T) {
+	h := newHarness(t, newFakeClient(), "lock")
+	// the fake clientset has no garbage collector
+	h.EmulateGC = true
+	candidates := h.StartCandidates(3)
+
+	first := h.EventuallyLeader()
+	h.ExpectSingleLeader()
+	killed := h.KillLeader()
+	if killed != first {
+		t.Errorf("killed %s, expected the leader %s", killed, first)
+	}
+	second := h.EventuallyLeader()
+	if second == killed {
+		t.Errorf("leadership stayed with killed candidate %s", killed)
+	}
+	h.ExpectSingleLeader()
+
+	h.Close()
+	for _, c := range candidates {
+		select {
+		case <-c.done:
+		default:
+			t.Errorf("election of %s is still running", c.Pod)
+		}
+		if err := c.Err(); err != nil {
+			t.Errorf("election of %s failed: %s", c.Pod, err.Error())
+		}
+	}
+}